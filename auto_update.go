@@ -75,9 +75,16 @@ func RunWithUpdateCheck(c RunnerUpdater) int {
 
 // PlatformZipURL compute the uri pointing at the given version of tgf zip
 func PlatformZipURL(version string) string {
-	name := runtime.GOOS
-	if name == "darwin" {
-		name = "macOS"
+	return platformZipURL(version, runtime.GOOS, runtime.GOARCH)
+}
+
+// platformZipURL returns the uri of the zip file for the os and architecture, the names match the archives published by goreleaser
+func platformZipURL(version, goos, goarch string) string {
+	if goos == "darwin" {
+		goos = "macOS"
+	}
+	if goarch == "amd64" {
+		goarch = "64-bits"
 	}
-	return fmt.Sprintf("https://github.com/coveo/tgf/releases/download/v%[1]s/tgf_%[1]s_%[2]s_64-bits.zip", version, name)
+	return fmt.Sprintf("https://github.com/coveo/tgf/releases/download/v%[1]s/tgf_%[1]s_%[2]s_%[3]s.zip", version, goos, goarch)
 }
@@ -100,3 +100,27 @@ func TestShouldUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestPlatformZipURL(t *testing.T) {
+	tests := []struct {
+		goos   string
+		goarch string
+		want   string
+	}{
+		{"linux", "amd64", "https://github.com/coveo/tgf/releases/download/v1.2.3/tgf_1.2.3_linux_64-bits.zip"},
+		{"linux", "arm64", "https://github.com/coveo/tgf/releases/download/v1.2.3/tgf_1.2.3_linux_arm64.zip"},
+		{"darwin", "amd64", "https://github.com/coveo/tgf/releases/download/v1.2.3/tgf_1.2.3_macOS_64-bits.zip"},
+		{"darwin", "arm64", "https://github.com/coveo/tgf/releases/download/v1.2.3/tgf_1.2.3_macOS_arm64.zip"},
+		{"windows", "amd64", "https://github.com/coveo/tgf/releases/download/v1.2.3/tgf_1.2.3_windows_64-bits.zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos+"/"+tt.goarch, func(t *testing.T) {
+			assert.Equal(t, tt.want, platformZipURL("1.2.3", tt.goos, tt.goarch))
+		})
+	}
+}
+
+func TestIsExecutableUpdatedSince(t *testing.T) {
+	assert.True(t, isExecutableUpdatedSince(time.Time{}))
+	assert.False(t, isExecutableUpdatedSince(time.Now().Add(time.Minute)))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	types_image "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/go-units"
+)
+
+const (
+	autoCleanupFile = "TGFAutoCleanup"
+
+	// Build files younger than this delay may belong to a build currently running in another tgf process
+	leftoverBuildFileDelay = 1 * time.Hour
+)
+
+// cleanupItem represents a resource created by tgf that could be removed
+type cleanupItem struct {
+	kind    string
+	name    string
+	size    int64
+	lastUse time.Time
+	remove  func() error
+}
+
+func (item cleanupItem) String() string {
+	return fmt.Sprintf("%s %s (%s, last used %s ago)", item.kind, item.name, units.HumanSize(float64(item.size)), units.HumanDuration(time.Since(item.lastUse)))
+}
+
+// cleanup removes the images built by tgf, the tgf volumes and the leftover build files according to the
+// cleanup policies defined in the configuration. If dryRun is set, the items are only listed.
+func (docker *dockerConfig) cleanup(dryRun bool) {
+	var maxSize int64
+	if docker.CleanupMaxSize != "" {
+		maxSize = must(units.FromHumanSize(docker.CleanupMaxSize)).(int64)
+	}
+
+	now := time.Now()
+	items := selectCleanupItems(append(getBuiltImages(), getUnusedVolumes()...), docker.CleanupMaxAge, maxSize, now)
+	items = append(items, docker.getLeftoverBuildFiles(now)...)
+	if len(items) == 0 {
+		log.Info("Nothing to clean up")
+		return
+	}
+
+	var total int64
+	for _, item := range items {
+		if dryRun {
+			fmt.Println("Would remove", item)
+		} else if err := item.remove(); err != nil {
+			log.Warningf("Unable to remove %s: %v", item, err)
+			continue
+		} else {
+			log.Info("Removed ", item)
+		}
+		total += item.size
+	}
+
+	if dryRun {
+		fmt.Println("Total:", units.HumanSize(float64(total)))
+		return
+	}
+	log.Info("Freed ", units.HumanSize(float64(total)))
+	pruneDangling()
+}
+
+// autoCleanup runs the cleanup if it is enabled in the configuration and if the delay since the last run has expired
+func (docker *dockerConfig) autoCleanup() {
+	if !docker.AutoCleanup || docker.tgf.DryRun || lastRefresh(autoCleanupFile) < docker.AutoCleanupDelay {
+		return
+	}
+	log.Debugf("More than %v since the last cleanup, cleaning up unused resources", docker.AutoCleanupDelay)
+	touchImageRefresh(autoCleanupFile)
+	docker.cleanup(false)
+}
+
+// selectCleanupItems returns the items that have not been used since maxAge, then the least recently used items
+// until the total size of the remaining ones is below maxSize. A zero value disables the corresponding policy.
+func selectCleanupItems(items []cleanupItem, maxAge time.Duration, maxSize int64, now time.Time) (selected []cleanupItem) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].lastUse.Before(items[j].lastUse) })
+
+	var remaining []cleanupItem
+	var total int64
+	for _, item := range items {
+		if maxAge > 0 && now.Sub(item.lastUse) > maxAge {
+			selected = append(selected, item)
+			continue
+		}
+		remaining = append(remaining, item)
+		total += item.size
+	}
+
+	for i := 0; maxSize > 0 && total > maxSize && i < len(remaining); i++ {
+		selected = append(selected, remaining[i])
+		total -= remaining[i].size
+	}
+	return
+}
+
+// getBuiltImages returns the images created through docker-image-build (identified by their hash label)
+func getBuiltImages() (items []cleanupItem) {
+	cli, ctx := getDockerClient()
+	filters := filters.NewArgs()
+	filters.Add("label", "hash")
+	images, err := cli.ImageList(ctx, types_image.ListOptions{Filters: filters})
+	if err != nil {
+		log.Errorln("Unable to list the built images:", err)
+		return
+	}
+
+	for _, image := range images {
+		id, tags := image.ID, image.RepoTags
+		item := cleanupItem{kind: "image", name: id, size: image.Size, lastUse: time.Unix(image.Created, 0)}
+		if len(tags) > 0 {
+			item.name = strings.Join(tags, ", ")
+		}
+		for _, tag := range tags {
+			if lastUse := getLastUse(tag); lastUse.After(item.lastUse) {
+				item.lastUse = lastUse
+			}
+		}
+		item.remove = func() error {
+			if len(tags) == 0 {
+				tags = []string{id}
+			}
+			for _, tag := range tags {
+				if _, err := cli.ImageRemove(ctx, tag, types_image.RemoveOptions{PruneChildren: true}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		items = append(items, item)
+	}
+	return
+}
+
+// getUnusedVolumes returns the tgf volumes that are not currently mounted by a container
+func getUnusedVolumes() (items []cleanupItem) {
+	cli, ctx := getDockerClient()
+	for _, v := range getTgfVolumes() {
+		if v.UsageData != nil && v.UsageData.RefCount > 0 {
+			log.Debugf("Volume %s is in use, it will not be removed", v.Name)
+			continue
+		}
+		lastUse, tracked := getVolumeLastUse(v.Name)
+		if !tracked {
+			log.Debugf("Volume %s has not been tracked yet, it is considered as used now", v.Name)
+			continue
+		}
+		name := v.Name
+		item := cleanupItem{kind: "volume", name: name, lastUse: lastUse}
+		if v.UsageData != nil {
+			item.size = v.UsageData.Size
+		}
+		item.remove = func() error { return cli.VolumeRemove(ctx, name, false) }
+		items = append(items, item)
+	}
+	return
+}
+
+// getTgfVolumes returns the volumes created by tgf (the cache volume and the home volumes) along with their usage
+func getTgfVolumes() (volumes []volume.Volume) {
+	cli, ctx := getDockerClient()
+	usage, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		log.Errorln("Unable to list the docker volumes:", err)
+		return
+	}
+	for _, v := range usage.Volumes {
+		if isTgfVolume(v.Name) {
+			volumes = append(volumes, *v)
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return
+}
+
+func isTgfVolume(name string) bool {
+	return name == dockerVolumeName || strings.HasPrefix(name, dockerVolumeName+"-")
+}
+
+// getVolumeLastUse returns the last time tgf mounted the volume. The volumes created before the tracking of their use (or by another
+// tgf version) are considered as used now, their creation time does not tell if they are still used.
+func getVolumeLastUse(name string) (lastUse time.Time, tracked bool) {
+	if lastUse = getLastUse(name); !lastUse.IsZero() {
+		return lastUse, true
+	}
+	touchLastUse(name)
+	return time.Now(), false
+}
+
+// getLeftoverBuildFiles returns the temporary build files that have not been removed by an interrupted tgf process
+func (docker *dockerConfig) getLeftoverBuildFiles(now time.Time) (items []cleanupItem) {
+	patterns := []string{filepath.Join(os.TempDir(), "tgf-dockerbuild*")}
+	for _, ib := range docker.imageBuildConfigs {
+		if ib.Folder != "" {
+			patterns = append(patterns, filepath.Join(ib.Dir(), dockerfilePattern+"*"))
+		}
+	}
+
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || now.Sub(info.ModTime()) < leftoverBuildFileDelay {
+				continue
+			}
+			file := match
+			item := cleanupItem{kind: "file", name: file, lastUse: info.ModTime(), remove: func() error { return os.RemoveAll(file) }}
+			filepath.Walk(file, func(_ string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					item.size += info.Size()
+				}
+				return nil
+			})
+			items = append(items, item)
+		}
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectCleanupItems(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	item := func(name string, size int64, age time.Duration) cleanupItem {
+		return cleanupItem{name: name, size: size, lastUse: now.Add(-age)}
+	}
+	items := []cleanupItem{
+		item("recent", 100, 1*time.Hour),
+		item("old", 100, 40*24*time.Hour),
+		item("middle", 300, 10*24*time.Hour),
+		item("older", 100, 20*24*time.Hour),
+	}
+
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		maxSize int64
+		want    []string
+	}{
+		{"No policy", 0, 0, nil},
+		{"Age only", 30 * 24 * time.Hour, 0, []string{"old"}},
+		{"Size only", 0, 400, []string{"old", "older"}},
+		{"Size already respected", 0, 1000, nil},
+		{"Age and size", 30 * 24 * time.Hour, 350, []string{"old", "older", "middle"}},
+		{"Age and size respected after age", 15 * 24 * time.Hour, 400, []string{"old", "older"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, selected := range selectCleanupItems(append([]cleanupItem(nil), items...), tt.maxAge, tt.maxSize, now) {
+				got = append(got, selected.name)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsTgfVolume(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isTgfVolume("tgf"))
+	assert.True(t, isTgfVolume("tgf-jsmith"))
+	assert.False(t, isTgfVolume("tgfother"))
+	assert.False(t, isTgfVolume("other-tgf"))
+}
+
+func TestGetLeftoverBuildFiles(t *testing.T) {
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	old := time.Now().Add(-2 * leftoverBuildFileDelay)
+
+	leftover := filepath.Join(tempDir, dockerfilePattern+"123456")
+	assert.NoError(t, os.WriteFile(leftover, []byte("FROM scratch\n"), 0644))
+	assert.NoError(t, os.Chtimes(leftover, old, old))
+	recent := filepath.Join(tempDir, dockerfilePattern+"654321")
+	assert.NoError(t, os.WriteFile(recent, []byte("FROM scratch\n"), 0644))
+	other := filepath.Join(tempDir, "Dockerfile")
+	assert.NoError(t, os.WriteFile(other, []byte("FROM scratch\n"), 0644))
+	assert.NoError(t, os.Chtimes(other, old, old))
+
+	docker := dockerConfig{&TGFConfig{imageBuildConfigs: []TGFConfigBuild{{Folder: tempDir}}}}
+	var files []string
+	for _, item := range docker.getLeftoverBuildFiles(time.Now()) {
+		if filepath.Dir(item.name) == tempDir {
+			files = append(files, item.name)
+			assert.Equal(t, int64(len("FROM scratch\n")), item.size)
+		}
+	}
+	assert.Equal(t, []string{leftover}, files)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/coveooss/gotemplate/v3/hcl"
@@ -82,32 +83,52 @@ const (
 // TGFApplication allows proper management between managed and non managed arguments provided to kingpin
 type TGFApplication struct {
 	*kingpin.Application
+	AttachContainer      string
 	AwsProfile           string
+	Cleanup              bool
 	ConfigFiles          string // pretty much called `config-paths` everywhere but here...
 	ConfigLocation       string
 	ConfigDump           bool
 	DisableUserConfig    bool
 	DockerBuild          bool
 	DockerInteractive    bool
+	DockerInteractiveSet bool
 	DockerOptions        []string
+	DryRun               bool
+	DryRunFormat         string
+	Each                 []string
+	EachParallelism      int
 	Entrypoint           string
+	Export               bool
 	FlushCache           bool
 	GetAllVersions       bool
 	GetCurrentVersion    bool
 	GetImageName         bool
 	Image                string
+	ImageDigest          string
 	ImageTag             string
 	ImageVersion         string
+	ListContainers       bool
+	ListVolumes          bool
+	LockFolder           bool
 	LoggingLevel         string
 	MountHomeDir         bool
 	MountPoint           string
 	MountTempDir         bool
+	Offline              bool
 	PruneImages          bool
 	PsPath               string
 	Refresh              bool
+	Shell                bool
+	StopContainers       []string
 	TempDirMountLocation MountLocation
 	UseAWS               bool
 	UseLocalImage        bool
+	VolumeArchive        string
+	VolumeExport         string
+	VolumeImport         string
+	VolumeShell          string
+	VolumeWipe           []string
 	WithCurrentUser      bool
 	WithDockerMount      bool
 	AutoUpdate           bool
@@ -132,6 +153,7 @@ func NewTGFApplication(args []string) *TGFApplication {
 	app.Flag("image", "Use the specified image instead of the default one").PlaceHolder("coveo/tgf").NoAutoShortcut().StringVar(&app.Image)
 	app.Flag("image-version", "Use a different version of docker image instead of the default one").PlaceHolder("version").Default("-").StringVar(&app.ImageVersion)
 	app.Flag("tag", "Use a different tag of docker image instead of the default one").Short('T').NoAutoShortcut().PlaceHolder("latest").Default("-").StringVar(&app.ImageTag)
+	app.Flag("image-digest", "Pin the docker image to the specified digest instead of using its version and tag").PlaceHolder("sha256:<digest>").StringVar(&app.ImageDigest)
 	app.Flag("local-image", "If set, TGF will not pull the image when refreshing").BoolVar(&app.UseLocalImage)
 	app.Flag("get-image-name", "Just return the resulting image name").Alias("gi").BoolVar(&app.GetImageName)
 	app.Flag("refresh-image", "Force a refresh of the docker image").BoolVar(&app.Refresh)
@@ -142,7 +164,8 @@ func NewTGFApplication(args []string) *TGFApplication {
 	debug := app.Flag("debug", "Print debug messages and docker commands issued").Short('D').Bool()
 	awsDebug := app.Flag("aws-debug", "Activate debug logging for the AWS SDK. This will print requests & responses made by the AWS SDK.").Bool()
 	app.Flag("flush-cache", "Invoke terragrunt with --terragrunt-update-source to flush the cache").Short('F').BoolVar(&app.FlushCache)
-	swFlagON("interactive", "Launch Docker in interactive mode").Alias("it").BoolVar(&app.DockerInteractive)
+	app.Flag("shell", "Start an interactive shell (bash, zsh or sh) in the tgf environment instead of the entry point").NoAutoShortcut().BoolVar(&app.Shell)
+	app.Flag("interactive", "Launch Docker in interactive mode (default: detected from the terminal and the CI environment)").Alias("it").IsSetByUser(&app.DockerInteractiveSet).BoolVar(&app.DockerInteractive)
 	swFlagON("docker-build", "Enable docker build instructions configured in the config files").BoolVar(&app.DockerBuild)
 	app.Flag("home", "Enable mapping of the home directory").BoolVar(&app.MountHomeDir)
 	swFlagON("temp", "Map the temp folder to a local folder (Deprecated: Use --temp-location host and --temp-location none)").IsSetByUser(&tempIsSetByUser).BoolVar(&temp)
@@ -154,9 +177,26 @@ func NewTGFApplication(args []string) *TGFApplication {
 	).IsSetByUser(&tempLocationIsSetByUser).PlaceHolder("folder").
 		EnumVar((*string)(&tempLocation), string(mountLocVolume), string(mountLocHost), string(mountLocNone))
 	app.Flag("mount-point", "Specify a mount point for the current folder").PlaceHolder("<folder>").Default("current_sources").StringVar(&app.MountPoint)
+	app.Flag("lock-folder", "Wait for the other tgf runs in the current folder to complete before running (see lock-folder)").BoolVar(&app.LockFolder)
+	app.Flag("offline", "Do not access the network, the cached remote configuration and the local images are used (see offline)").NoAutoShortcut().BoolVar(&app.Offline)
 	app.Flag("prune", "Remove all previous versions of the targeted image").BoolVar(&app.PruneImages)
+	app.Flag("cleanup", "Remove the images built by tgf, the tgf volumes and the leftover build files that are no longer used").NoAutoShortcut().BoolVar(&app.Cleanup)
+	app.Flag("dry-run", "Only show what would be done, the docker invocation is printed instead of being run (see --dry-run-format)").BoolVar(&app.DryRun)
+	app.Flag("dry-run-format", "Format of the docker invocation printed by --dry-run (script or json)").PlaceHolder("<format>").Default(dryRunScript).EnumVar(&app.DryRunFormat, dryRunScript, dryRunJSON)
+	app.Flag("export", "Generate .devcontainer/devcontainer.json and compose.yaml running the same image, mounts and environment").NoAutoShortcut().BoolVar(&app.Export)
+	app.Flag(eachFlag, "Run the command in each folder matching the glob pattern (e.g. 'accounts/*/us-east-1') with the configuration of the folder").PlaceHolder("<pattern>").NoAutoShortcut().StringsVar(&app.Each)
+	app.Flag(eachParallelismFlag, "Maximum number of folders processed at the same time by --each").PlaceHolder("<count>").Default(strconv.Itoa(defaultEachParallelism)).NoAutoShortcut().IntVar(&app.EachParallelism)
+	app.Flag("list-containers", "List the running tgf containers with their launch folder and command").BoolVar(&app.ListContainers)
+	app.Flag("attach-container", "Attach the terminal to the specified running tgf container").PlaceHolder("<container>").StringVar(&app.AttachContainer)
+	app.Flag("stop-container", "Interrupt the specified tgf container, it is killed if it does not exit within a minute").PlaceHolder("<container>").StringsVar(&app.StopContainers)
+	app.Flag("list-volumes", "List the tgf volumes with their size and last use").BoolVar(&app.ListVolumes)
+	app.Flag("volume-shell", "Open a shell in the specified tgf volume").PlaceHolder("<volume>").StringVar(&app.VolumeShell)
+	app.Flag("volume-export", "Export the content of the specified tgf volume to a tarball (see --volume-archive)").PlaceHolder("<volume>").StringVar(&app.VolumeExport)
+	app.Flag("volume-import", "Import a tarball created by --volume-export into the specified tgf volume (see --volume-archive)").PlaceHolder("<volume>").StringVar(&app.VolumeImport)
+	app.Flag("volume-archive", "Tarball used by --volume-export and --volume-import (default: <volume>.tar.gz)").PlaceHolder("<file>").StringVar(&app.VolumeArchive)
+	app.Flag("volume-wipe", "Remove the specified tgf volume, it is recreated empty on the next run").PlaceHolder("<volume>").StringsVar(&app.VolumeWipe)
 	app.Flag("docker-arg", "Supply extra argument to Docker").PlaceHolder("<opt>").StringsVar(&app.DockerOptions)
-	app.Flag("with-current-user", "Runs the docker command with the current user, using the --user arg").Alias("cu").BoolVar(&app.WithCurrentUser)
+	app.Flag("with-current-user", "Runs the docker command with the current user, using the --user arg (with a matching passwd entry and a writable home)").Alias("cu").BoolVar(&app.WithCurrentUser)
 	app.Flag("with-docker-mount", "Mounts the docker socket to the image so the host's docker api is usable").Alias("wd", "dm").BoolVar(&app.WithDockerMount)
 	app.Flag("ignore-user-config", "Ignore all tgf.user.config files").Alias("iu", "iuc").NoAutoShortcut().BoolVar(&app.DisableUserConfig)
 	swFlagON("aws", "Use AWS Parameter store to get configuration").BoolVar(&app.UseAWS)
@@ -173,6 +213,11 @@ func NewTGFApplication(args []string) *TGFApplication {
 
 	_, _ = app.Parse(args)
 
+	if os.Getenv("TGF_INTERACTIVE") != "" {
+		// The environment variable is an explicit choice as well
+		app.DockerInteractiveSet = true
+	}
+
 	if *debug {
 		_ = log.SetDefaultConsoleHookLevel(logrus.DebugLevel)
 	}
@@ -233,19 +278,22 @@ func formatDescription() string {
 	return strings.TrimSpace(must(t.ProcessContent(description, "")).(string))
 }
 
+// getEnvArgs returns the arguments specified through the TGF_ARGS env variable
+func getEnvArgs() []string {
+	nonEmptyArgs := []string{}
+	for _, extraArg := range strings.Split(os.Getenv(envArgs), " ") {
+		extraArg = strings.TrimSpace(extraArg)
+		if extraArg != "" {
+			nonEmptyArgs = append(nonEmptyArgs, extraArg)
+		}
+	}
+	return nonEmptyArgs
+}
+
 // Parse overrides the base Parse method
 func (app *TGFApplication) Parse(args []string) (command string, err error) {
 	// Add args from the TGF_ARGS env variable
-	if extraArgs, ok := os.LookupEnv(envArgs); ok {
-		nonEmptyArgs := []string{}
-		for _, extraArg := range strings.Split(extraArgs, " ") {
-			extraArg = strings.TrimSpace(extraArg)
-			if extraArg != "" {
-				nonEmptyArgs = append(nonEmptyArgs, extraArg)
-			}
-		}
-		args = append(nonEmptyArgs, args...)
-	}
+	args = append(getEnvArgs(), args...)
 	if command, err = app.Application.Parse(args); err != nil {
 		panic(errors.Managed(err.Error()))
 	}
@@ -283,5 +331,10 @@ func (app *TGFApplication) Run() int {
 		return 0
 	}
 
+	if len(app.Each) > 0 {
+		// The configuration is read by the run in each folder
+		return app.runEach()
+	}
+
 	return RunWithUpdateCheck(InitConfig(app))
 }
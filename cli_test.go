@@ -43,14 +43,14 @@ func TestNewApplicationWithOptionsAndAliases(t *testing.T) {
 			"Managed arg",
 			[]string{"--ri"},
 			"",
-			map[string]interface{}{"Refresh": true, "DockerInteractive": true},
+			map[string]interface{}{"Refresh": true, "DockerInteractive": false},
 			nil,
 		},
 		{
 			"Managed and unmanaged arg",
 			[]string{"--li", "--stuff"},
 			"",
-			map[string]interface{}{"UseLocalImage": true, "DockerInteractive": true},
+			map[string]interface{}{"UseLocalImage": true, "DockerInteractive": false},
 			[]string{"--stuff"},
 		},
 		{
@@ -64,21 +64,21 @@ func TestNewApplicationWithOptionsAndAliases(t *testing.T) {
 			"Alias with an argument",
 			[]string{"my_recursive_alias", "--no-interactive"},
 			"",
-			map[string]interface{}{"DockerInteractive": false},
+			map[string]interface{}{"DockerInteractive": false, "DockerInteractiveSet": true},
 			[]string{"--stuff3"},
 		},
 		{
 			"Disable flag (shown as `no` in the help)",
 			[]string{"--no-aws"},
 			"",
-			map[string]interface{}{"UseAWS": false, "DockerInteractive": true},
+			map[string]interface{}{"UseAWS": false, "DockerInteractive": false},
 			nil,
 		},
 		{
 			"Disable short flag (shown as `no` in the help)",
 			[]string{"--na"},
 			"",
-			map[string]interface{}{"UseAWS": false, "DockerInteractive": true},
+			map[string]interface{}{"UseAWS": false, "DockerInteractive": false},
 			nil,
 		},
 		{
@@ -28,6 +28,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/blang/semver/v4"
 	"github.com/coveooss/gotemplate/v3/collections"
+	"github.com/docker/go-units"
 	"github.com/fatih/color"
 	"github.com/hashicorp/go-getter"
 	"github.com/minio/selfupdate"
@@ -55,21 +56,51 @@ type TGFConfig struct {
 	Image                   string            `yaml:"docker-image,omitempty" json:"docker-image,omitempty" hcl:"docker-image,omitempty"`
 	ImageVersion            *string           `yaml:"docker-image-version,omitempty" json:"docker-image-version,omitempty" hcl:"docker-image-version,omitempty"`
 	ImageTag                *string           `yaml:"docker-image-tag,omitempty" json:"docker-image-tag,omitempty" hcl:"docker-image-tag,omitempty"`
+	ImageDigest             string            `yaml:"docker-image-digest,omitempty" json:"docker-image-digest,omitempty" hcl:"docker-image-digest,omitempty"`
 	ImageBuild              string            `yaml:"docker-image-build,omitempty" json:"docker-image-build,omitempty" hcl:"docker-image-build,omitempty"`
 	ImageBuildFolder        string            `yaml:"docker-image-build-folder,omitempty" json:"docker-image-build-folder,omitempty" hcl:"docker-image-build-folder,omitempty"`
 	ImageBuildTag           string            `yaml:"docker-image-build-tag,omitempty" json:"docker-image-build-tag,omitempty" hcl:"docker-image-build-tag,omitempty"`
 	LogLevel                string            `yaml:"logging-level,omitempty" json:"logging-level,omitempty" hcl:"logging-level,omitempty"`
 	EntryPoint              string            `yaml:"entry-point,omitempty" json:"entry-point,omitempty" hcl:"entry-point,omitempty"`
 	Refresh                 time.Duration     `yaml:"docker-refresh,omitempty" json:"docker-refresh,omitempty" hcl:"docker-refresh,omitempty"`
+	Platform                string            `yaml:"docker-platform,omitempty" json:"docker-platform,omitempty" hcl:"docker-platform,omitempty"`
+	PullRetries             int               `yaml:"docker-pull-retries,omitempty" json:"docker-pull-retries,omitempty" hcl:"docker-pull-retries,omitempty"`
+	PullTimeout             time.Duration     `yaml:"docker-pull-timeout,omitempty" json:"docker-pull-timeout,omitempty" hcl:"docker-pull-timeout,omitempty"`
 	DockerOptions           []string          `yaml:"docker-options,omitempty" json:"docker-options,omitempty" hcl:"docker-options,omitempty"`
+	CPUs                    string            `yaml:"cpus,omitempty" json:"cpus,omitempty" hcl:"cpus,omitempty"`
+	Memory                  string            `yaml:"memory,omitempty" json:"memory,omitempty" hcl:"memory,omitempty"`
+	Network                 string            `yaml:"network,omitempty" json:"network,omitempty" hcl:"network,omitempty"`
+	DNS                     []string          `yaml:"dns,omitempty" json:"dns,omitempty" hcl:"dns,omitempty"`
+	AddHosts                []string          `yaml:"add-hosts,omitempty" json:"add-hosts,omitempty" hcl:"add-hosts,omitempty"`
+	Publish                 []string          `yaml:"publish,omitempty" json:"publish,omitempty" hcl:"publish,omitempty"`
+	MountScope              string            `yaml:"mount-scope,omitempty" json:"mount-scope,omitempty" hcl:"mount-scope,omitempty"`
+	MountReadOnly           bool              `yaml:"mount-read-only,omitempty" json:"mount-read-only,omitempty" hcl:"mount-read-only,omitempty"`
+	MountRelativeSources    bool              `yaml:"mount-relative-sources,omitempty" json:"mount-relative-sources,omitempty" hcl:"mount-relative-sources,omitempty"`
+	RemoteSync              string            `yaml:"remote-sync,omitempty" json:"remote-sync,omitempty" hcl:"remote-sync,omitempty"`
+	RemoteSyncBack          []string          `yaml:"remote-sync-back,omitempty" json:"remote-sync-back,omitempty" hcl:"remote-sync-back,omitempty"`
+	LockFolder              bool              `yaml:"lock-folder,omitempty" json:"lock-folder,omitempty" hcl:"lock-folder,omitempty"`
+	Offline                 bool              `yaml:"offline,omitempty" json:"offline,omitempty" hcl:"offline,omitempty"`
+	RegistryAuth            []TGFConfigAuth   `yaml:"registry-auth,omitempty" json:"registry-auth,omitempty" hcl:"registry-auth,omitempty"`
+	Mounts                  []TGFConfigMount  `yaml:"mounts,omitempty" json:"mounts,omitempty" hcl:"mounts,omitempty"`
+	HomeMounts              []string          `yaml:"home-mounts,omitempty" json:"home-mounts,omitempty" hcl:"home-mounts,omitempty"`
+	ForwardSSHAgent         bool              `yaml:"forward-ssh-agent,omitempty" json:"forward-ssh-agent,omitempty" hcl:"forward-ssh-agent,omitempty"`
+	ForwardGitConfig        bool              `yaml:"forward-git-config,omitempty" json:"forward-git-config,omitempty" hcl:"forward-git-config,omitempty"`
+	ForwardKnownHosts       bool              `yaml:"forward-known-hosts,omitempty" json:"forward-known-hosts,omitempty" hcl:"forward-known-hosts,omitempty"`
+	GitCredentialHosts      []string          `yaml:"git-credential-hosts,omitempty" json:"git-credential-hosts,omitempty" hcl:"git-credential-hosts,omitempty"`
 	RecommendedImageVersion string            `yaml:"recommended-image-version,omitempty" json:"recommended-image-version,omitempty" hcl:"recommended-image-version,omitempty"`
 	RequiredVersionRange    string            `yaml:"required-image-version,omitempty" json:"required-image-version,omitempty" hcl:"required-image-version,omitempty"`
 	RecommendedTGFVersion   string            `yaml:"tgf-recommended-version,omitempty" json:"tgf-recommended-version,omitempty" hcl:"tgf-recommended-version,omitempty"`
 	Environment             map[string]string `yaml:"environment,omitempty" json:"environment,omitempty" hcl:"environment,omitempty"`
+	SecretsAsFiles          bool              `yaml:"secrets-as-files,omitempty" json:"secrets-as-files,omitempty" hcl:"secrets-as-files,omitempty"`
+	SecretVariables         []string          `yaml:"secret-variables,omitempty" json:"secret-variables,omitempty" hcl:"secret-variables,omitempty"`
 	Aliases                 map[string]string `yaml:"alias,omitempty" json:"alias,omitempty" hcl:"alias,omitempty"`
 	UpdateVersion           string            `yaml:"update-version,omitempty" json:"update-version,omitempty" hcl:"update-version,omitempty"`
 	AutoUpdateDelay         time.Duration     `yaml:"auto-update-delay,omitempty" json:"auto-update-delay,omitempty" hcl:"auto-update-delay,omitempty"`
 	AutoUpdate              bool              `yaml:"auto-update,omitempty" json:"auto-update,omitempty" hcl:"auto-update,omitempty"`
+	AutoCleanup             bool              `yaml:"auto-cleanup,omitempty" json:"auto-cleanup,omitempty" hcl:"auto-cleanup,omitempty"`
+	AutoCleanupDelay        time.Duration     `yaml:"auto-cleanup-delay,omitempty" json:"auto-cleanup-delay,omitempty" hcl:"auto-cleanup-delay,omitempty"`
+	CleanupMaxAge           time.Duration     `yaml:"cleanup-max-age,omitempty" json:"cleanup-max-age,omitempty" hcl:"cleanup-max-age,omitempty"`
+	CleanupMaxSize          string            `yaml:"cleanup-max-size,omitempty" json:"cleanup-max-size,omitempty" hcl:"cleanup-max-size,omitempty"`
 
 	imageBuildConfigs []TGFConfigBuild // List of config built from previous build configs
 	tgf                                 *TGFApplication
@@ -80,6 +111,7 @@ type TGFConfigBootstrap struct {
 	ConfigLocation string `yaml:"config-location,omitempty" json:"config-location,omitempty" hcl:"config-location,omitempty"`
 	ConfigPaths    string `yaml:"config-paths,omitempty" json:"config-paths,omitempty" hcl:"config-paths,omitempty"`
 	SSMPath        string `yaml:"ssm-path,omitempty" json:"ssm-path,omitempty" hcl:"ssm-path,omitempty"`
+	Offline        bool   `yaml:"offline,omitempty" json:"offline,omitempty" hcl:"offline,omitempty"`
 }
 
 // TGFConfigBuild contains an entry specifying how to customize the current docker image
@@ -143,8 +175,13 @@ func InitConfig(app *TGFApplication) *TGFConfig {
 	config := TGFConfig{Image: "coveo/tgf",
 		tgf:               app,
 		Refresh:           1 * time.Hour,
+		PullRetries:       defaultPullRetries,
+		PullTimeout:       defaultPullTimeout,
 		AutoUpdateDelay:   2 * time.Hour,
 		AutoUpdate:        true,
+		AutoCleanupDelay:  7 * 24 * time.Hour,
+		RemoteSyncBack:    []string{".terraform.lock.hcl"},
+		CleanupMaxAge:     30 * 24 * time.Hour,
 		EntryPoint:        "terragrunt",
 		LogLevel:          "notice",
 		Environment:       make(map[string]string),
@@ -368,6 +405,10 @@ func (config *TGFConfig) setBootstrapVariablesFromLocalFiles() {
 		if app.PsPath == defaultSSMParameterFolder && localConfig.SSMPath != "" {
 			app.PsPath = localConfig.SSMPath
 		}
+		if localConfig.Offline {
+			// The offline mode must be known before reading the remote configuration
+			app.Offline = true
+		}
 	}
 }
 
@@ -441,7 +482,8 @@ func (config *TGFConfig) setDefaultValues() {
 		collections.ConvertData(configData.Raw, &configData.Config)
 	}
 
-	// Special case for image build configs and run before/after, we must build a list of instructions from all configs
+	// Special case for image build configs, mounts, network lists, registry credentials and run before/after, we must build a list of instructions from all configs
+	config.Mounts, config.DNS, config.AddHosts, config.Publish, config.RegistryAuth = nil, nil, nil, nil, nil
 	for i := range configsData {
 		configData := &configsData[i]
 		if configData.Config == nil {
@@ -456,9 +498,15 @@ func (config *TGFConfig) setDefaultValues() {
 				source:       configData.Name,
 			}}, config.imageBuildConfigs...)
 		}
+		config.Mounts = mergeMounts(config.Mounts, configData.Name, configData.Config.Mounts...)
+		config.RegistryAuth = mergeRegistryAuth(config.RegistryAuth, configData.Config.RegistryAuth...)
+		config.DNS = mergeValues(config.DNS, configData.Config.DNS...)
+		config.AddHosts = mergeValues(config.AddHosts, configData.Config.AddHosts...)
+		config.Publish = mergeValues(config.Publish, configData.Config.Publish...)
 	}
 }
 
+var reDigest = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
 var reVersion = regexp.MustCompile(`(?P<version>\d+\.\d+(?:\.\d+){0,1})`)
 var reVersionWithEndMarkers = regexp.MustCompile(`^` + reVersion.String() + `$`)
 
@@ -483,6 +531,53 @@ func (config *TGFConfig) validate() (errors []error) {
 		errors = append(errors, ConfigWarning(fmt.Sprintf("Image tag parameter should not contain the image name: %s", *config.ImageTag)))
 	}
 
+	if config.ImageDigest != "" && !reDigest.MatchString(config.imageDigest()) {
+		errors = append(errors, fmt.Errorf("invalid image digest %s, it should be in the form sha256:<64 hexadecimal characters>", config.ImageDigest))
+	}
+
+	if config.CleanupMaxSize != "" {
+		if _, err := units.FromHumanSize(config.CleanupMaxSize); err != nil {
+			errors = append(errors, fmt.Errorf("invalid cleanup-max-size %s: %v", config.CleanupMaxSize, err))
+		}
+	}
+
+	if config.PullRetries < 0 {
+		errors = append(errors, fmt.Errorf("invalid docker-pull-retries %d, it must be positive", config.PullRetries))
+	}
+
+	if err := validatePlatform(config.Platform); err != nil {
+		errors = append(errors, err)
+	}
+	errors = append(errors, config.validateResources()...)
+
+	switch config.RemoteSync {
+	case "", remoteSyncAuto, remoteSyncAlways, remoteSyncNever:
+	default:
+		errors = append(errors, fmt.Errorf("invalid remote-sync %s, it must be %s, %s or %s", config.RemoteSync, remoteSyncAuto, remoteSyncAlways, remoteSyncNever))
+	}
+
+	if err := validateMountScope(config.MountScope); err != nil {
+		errors = append(errors, err)
+	}
+
+	for _, ra := range config.RegistryAuth {
+		if err := ra.validate(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	for _, m := range config.Mounts {
+		if err := m.validate(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	for _, entry := range config.HomeMounts {
+		if err := validateHomeMount(entry); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
 	if config.RecommendedTGFVersion != "" && version != locallyBuilt {
 		if valid, err := CheckVersionRange(version, config.RecommendedTGFVersion); err != nil {
 			errors = append(errors, fmt.Errorf("unable to check recommended tgf version %s vs %s: %v", version, config.RecommendedTGFVersion, err))
@@ -540,8 +635,20 @@ func (config *TGFConfig) IsPartialVersion() bool {
 		strings.Count(*config.ImageVersion, ".") == 1
 }
 
+// imageDigest returns the configured digest with its algorithm prefix
+func (config *TGFConfig) imageDigest() string {
+	if config.ImageDigest == "" || strings.Contains(config.ImageDigest, ":") {
+		return config.ImageDigest
+	}
+	return "sha256:" + config.ImageDigest
+}
+
 // GetImageName returns the actual image name
 func (config *TGFConfig) GetImageName() string {
+	if config.ImageDigest != "" {
+		// The digest identifies the exact image content, so version and tag are irrelevant
+		return fmt.Sprintf("%s@%s", config.Image, config.imageDigest())
+	}
 	var suffix string
 	if config.ImageVersion != nil {
 		suffix += *config.ImageVersion
@@ -631,6 +738,13 @@ func (config *TGFConfig) findRemoteConfigFiles(location, files string) []string
 	for _, configPath := range configPaths {
 		fullConfigPath := location + configPath
 		destConfigPath := path.Join(tempDir, configPath)
+		if config.tgf.Offline {
+			log.Debugln("Reading the cached configuration of", fullConfigPath)
+			if content := mustReadCachedRemoteConfig(fullConfigPath); content != "" {
+				configs = append(configs, content)
+			}
+			continue
+		}
 		log.Debugln("Reading configuration from", fullConfigPath)
 		source := must(getter.Detect(fullConfigPath, must(os.Getwd()).(string), getter.Detectors)).(string)
 
@@ -643,6 +757,13 @@ func (config *TGFConfig) findRemoteConfigFiles(location, files string) []string
 		}
 
 		if err != nil {
+			if content, cacheErr := readCachedRemoteConfig(fullConfigPath); cacheErr == nil {
+				log.Warningf("Error fetching config at %s: %v, using the cached version", source, err)
+				if content != "" {
+					configs = append(configs, content)
+				}
+				continue
+			}
 			log.Warningf("Error fetching config at %s: %v", source, err)
 			continue
 		}
@@ -651,6 +772,7 @@ func (config *TGFConfig) findRemoteConfigFiles(location, files string) []string
 			log.Warningf("Error reading fetched config file %s: %v", configPath, err)
 		} else {
 			contentString := string(content)
+			cacheRemoteConfig(fullConfigPath, contentString)
 			if contentString != "" {
 				configs = append(configs, contentString)
 			}
@@ -687,6 +809,10 @@ func (config TGFConfig) awsConfigExist() (result bool) {
 		log.Debugln("Not trying to read the config from AWS. It is disabled")
 		return false
 	}
+	if app.Offline {
+		log.Debugln("Not trying to read the config from AWS. The offline mode is enabled")
+		return false
+	}
 
 	log.Debugln("Checking if the TGF configuration should be read from AWS SSM. This will happen if any of the following are true:")
 
@@ -819,6 +945,10 @@ func (config *TGFConfig) GetUpdateVersion() (string, error) {
 // ShouldUpdate evaluate wether tgf updater should run or not depending on cli options and config file
 func (config *TGFConfig) ShouldUpdate() bool {
 	app := config.tgf
+	if app.Offline {
+		log.Debug("The offline mode is enabled. Bypassing update version check.")
+		return false
+	}
 	if app.AutoUpdateSet {
 		if app.AutoUpdate {
 			if version == locallyBuilt {
@@ -881,6 +1011,15 @@ func (config *TGFConfig) getTgfFile(url string) (tgfFile io.ReadCloser, err erro
 
 // DoUpdate fetch the executable from the link, unzip it and replace it with the current
 func (config *TGFConfig) DoUpdate(url string) (err error) {
+	start := time.Now()
+	release, waited := acquireLock("self-update")
+	defer release()
+	if waited && isExecutableUpdatedSince(start) {
+		// The process holding the lock has already installed the update, restarting is enough to use it
+		log.Debug("TGF has been updated by another process")
+		return
+	}
+
 	savePath, err := os.MkdirTemp("", "tgf.previous-version")
 	if err != nil {
 		return
@@ -899,6 +1038,19 @@ func (config *TGFConfig) DoUpdate(url string) (err error) {
 	return
 }
 
+// isExecutableUpdatedSince checks if the tgf executable has been replaced since the specified time
+func isExecutableUpdatedSince(t time.Time) bool {
+	executable, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	info, err := os.Stat(executable)
+	return err == nil && info.ModTime().After(t)
+}
+
 // GetLastRefresh get the last time the tgf update file was updated
 func (config *TGFConfig) GetLastRefresh(autoUpdateFile string) time.Duration {
 	return lastRefresh(autoUpdateFile)
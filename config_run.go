@@ -17,6 +17,7 @@ func (config *TGFConfig) Run() int {
 		config.RequiredVersionRange = ""
 		config.ImageVersion = nil
 		config.ImageTag = nil
+		config.ImageDigest = ""
 	}
 	if app.ImageVersion != "-" {
 		config.ImageVersion = &app.ImageVersion
@@ -24,9 +25,25 @@ func (config *TGFConfig) Run() int {
 	if app.ImageTag != "-" {
 		config.ImageTag = &app.ImageTag
 	}
+	if app.ImageDigest != "" {
+		config.ImageDigest = app.ImageDigest
+	} else if app.ImageVersion != "-" || app.ImageTag != "-" {
+		// An explicit version or tag on the command line has precedence over a digest pinned in the configuration
+		config.ImageDigest = ""
+	}
+	if config.ImageDigest != "" {
+		// The version will be read from the pinned image itself once it is available locally
+		config.ImageVersion = nil
+	}
 	if app.Entrypoint != "" {
 		config.EntryPoint = app.Entrypoint
 	}
+	if app.LockFolder {
+		config.LockFolder = true
+	}
+	// The offline mode can also be enabled by the configuration files
+	app.Offline = app.Offline || config.Offline
+	config.Offline = app.Offline
 	if !config.ValidateVersion() {
 		return 1
 	}
@@ -45,11 +62,44 @@ func (config *TGFConfig) Run() int {
 		app.Unmanaged = []string{"get-versions"}
 	}
 
+	if app.Export {
+		// The definitions are generated from the docker invocation, so nothing must be pulled, built or run
+		app.DryRun = true
+	}
+
 	docker := dockerConfig{config}
+	if app.Cleanup {
+		docker.cleanup(app.DryRun)
+		return 0
+	}
+
+	switch {
+	case app.ListContainers:
+		listContainers()
+		return 0
+	case app.AttachContainer != "":
+		return attachContainer(app.AttachContainer)
+	case len(app.StopContainers) > 0:
+		return stopContainers(app.StopContainers...)
+	}
+
+	if app.ListVolumes {
+		listVolumes()
+		return 0
+	}
+
+	if len(app.VolumeWipe) > 0 {
+		return wipeVolumes(app.VolumeWipe...)
+	}
+
 	imageName := config.GetImageName()
-	if lastRefresh(imageName) > config.Refresh || config.IsPartialVersion() || !checkImage(imageName) || app.Refresh {
+	// An image pinned by digest never changes, so there is no need to check for a newer version periodically
+	refreshDue := config.ImageDigest == "" && lastRefresh(imageName) > config.Refresh
+	// Nothing is pulled in dry run mode
+	if !app.DryRun && (refreshDue || config.IsPartialVersion() || !checkImage(imageName) || app.Refresh || !docker.hasExpectedPlatform(imageName)) {
 		docker.refreshImage(imageName)
 	}
+	docker.checkImagePlatform(imageName)
 
 	if app.LoggingLevel != "" {
 		config.LogLevel = app.LoggingLevel
@@ -60,7 +110,16 @@ func (config *TGFConfig) Run() int {
 		return 0
 	}
 
-	if config.EntryPoint == "terragrunt" && app.Unmanaged == nil && !app.GetImageName {
+	switch {
+	case app.VolumeShell != "":
+		return docker.volumeShell(app.VolumeShell, imageName)
+	case app.VolumeExport != "":
+		return docker.exportVolume(app.VolumeExport, imageName, app.VolumeArchive)
+	case app.VolumeImport != "":
+		return docker.importVolume(app.VolumeImport, imageName, app.VolumeArchive)
+	}
+
+	if config.EntryPoint == "terragrunt" && app.Unmanaged == nil && !app.GetImageName && !app.Shell {
 		title := color.New(color.FgYellow, color.Underline).SprintFunc()
 		log.Println(title("\nTGF Usage"))
 		app.Usage(nil)
@@ -74,5 +133,7 @@ func (config *TGFConfig) Run() int {
 		}
 	}
 
-	return docker.call()
+	exitCode := docker.call()
+	docker.autoCleanup()
+	return exitCode
 }
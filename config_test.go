@@ -375,6 +375,7 @@ func TestGetImageName(t *testing.T) {
 		image         string
 		version       *string
 		tag           *string
+		digest        string
 		expectedImage string
 	}{
 		{
@@ -425,6 +426,18 @@ func TestGetImageName(t *testing.T) {
 			tag:           aws.String("3.0.0"),
 			expectedImage: "coveo/tgf:3.0.0-3.0.0",
 		},
+		{
+			image:         "coveo/tgf",
+			version:       aws.String("3.0.0"),
+			tag:           aws.String("aws"),
+			digest:        "sha256:" + strings.Repeat("a", 64),
+			expectedImage: "coveo/tgf@sha256:" + strings.Repeat("a", 64),
+		},
+		{
+			image:         "coveo/tgf",
+			digest:        strings.Repeat("b", 64),
+			expectedImage: "coveo/tgf@sha256:" + strings.Repeat("b", 64),
+		},
 	}
 	for _, tt := range cases {
 		tt := tt
@@ -433,12 +446,41 @@ func TestGetImageName(t *testing.T) {
 				Image:        tt.image,
 				ImageVersion: tt.version,
 				ImageTag:     tt.tag,
+				ImageDigest:  tt.digest,
 			}
 			assert.Equal(t, tt.expectedImage, config.GetImageName())
 		})
 	}
 }
 
+func TestValidateImageDigest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		digest  string
+		wantErr bool
+	}{
+		{"No digest", "", false},
+		{"Full digest", "sha256:" + strings.Repeat("0123456789abcdef", 4), false},
+		{"Digest without algorithm", strings.Repeat("0123456789abcdef", 4), false},
+		{"Too short", "sha256:0123456789abcdef", true},
+		{"Unsupported algorithm", "md5:" + strings.Repeat("0123456789abcdef", 4), true},
+		{"Uppercase", "sha256:" + strings.Repeat("0123456789ABCDEF", 4), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &TGFConfig{Image: "coveo/tgf", ImageDigest: tt.digest}
+			hasErr := false
+			for _, err := range config.validate() {
+				if _, isWarning := err.(ConfigWarning); !isWarning {
+					hasErr = true
+				}
+			}
+			assert.Equal(t, tt.wantErr, hasErr)
+		})
+	}
+}
+
 func writeSSMConfig(parameterFolder, parameterKey, parameterValue string) {
 	fullParameterKey := fmt.Sprintf("%s/%s", parameterFolder, parameterKey)
 	client := getSSMClient()
@@ -536,6 +578,7 @@ func TestSetConfigLocationFromLocalFiles(t *testing.T) {
 		expectedConfigLocation string
 		expectedConfigPaths    string
 		expectedSSMPath        string
+		expectedOffline        bool
 		disableUserConfig      bool
 	}{
 		{
@@ -610,6 +653,13 @@ config-paths = "HclConfig"`,
 				".tgf.config": ``,
 			},
 		},
+		{
+			name: "Offline mode from .tgf.config",
+			configFiles: map[string]string{
+				".tgf.config": `offline: true`,
+			},
+			expectedOffline: true,
+		},
 		{
 			name: "No bootstrap fields in config",
 			configFiles: map[string]string{
@@ -652,6 +702,7 @@ config-paths = "HclConfig"`,
 				expectedPsPath = defaultSSMParameterFolder // Default should remain if not set
 			}
 			assert.Equal(t, expectedPsPath, app.PsPath, "PsPath mismatch")
+			assert.Equal(t, tt.expectedOffline, app.Offline, "Offline mismatch")
 		})
 	}
 }
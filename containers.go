@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/go-units"
+)
+
+// Labels added to the containers started by tgf
+const (
+	containerLabelVersion = "tgf.version"
+	containerLabelFolder  = "tgf.folder"
+	containerLabelCommand = "tgf.command"
+	containerLabelImage   = "tgf.image"
+	containerLabelStarted = "tgf.started"
+)
+
+// Delay given to the process to exit after the interruption signal before it is killed (terraform must release its locks)
+const containerStopTimeout = 60
+
+// getContainerLabels returns the labels identifying a container started by tgf
+func getContainerLabels(folder, image string, command []string) map[string]string {
+	return map[string]string{
+		containerLabelVersion: version,
+		containerLabelFolder:  folder,
+		containerLabelCommand: strings.Join(command, " "),
+		containerLabelImage:   image,
+		containerLabelStarted: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// getLabelArgs returns the docker arguments adding the labels (sorted by name)
+func getLabelArgs(labels map[string]string) (args []string) {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", name, labels[name]))
+	}
+	return
+}
+
+// listContainers prints the running tgf containers along with their launch folder and command
+func listContainers() {
+	cli, ctx := getDockerClient()
+	containers := must(cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", containerLabelVersion)),
+	})).([]container.Summary)
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Created < containers[j].Created })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tFOLDER\tCOMMAND\tIMAGE\tSTARTED\tSTATUS")
+	for _, c := range containers {
+		started := time.Unix(c.Created, 0)
+		if t, err := time.Parse(time.RFC3339, c.Labels[containerLabelStarted]); err == nil {
+			started = t
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s ago\t%s\n", c.ID[:12], c.Labels[containerLabelFolder], c.Labels[containerLabelCommand],
+			c.Labels[containerLabelImage], units.HumanDuration(time.Since(started)), c.Status)
+	}
+	w.Flush()
+}
+
+// checkContainer ensures that the container exists and has been started by tgf, it returns its full identifier
+func checkContainer(name string) (string, error) {
+	cli, ctx := getDockerClient()
+	c, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("unable to find container %s: %v", name, err)
+	}
+	if _, isTgf := c.Config.Labels[containerLabelVersion]; !isTgf {
+		return "", fmt.Errorf("%s is not a tgf container", name)
+	}
+	return c.ID, nil
+}
+
+// attachContainer attaches the terminal to a running tgf container
+func attachContainer(name string) int {
+	id, err := checkContainer(name)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	// Ctrl-C must detach the terminal instead of interrupting the command running in the container
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	log.Info("Press Ctrl-C to detach (or Ctrl-P Ctrl-Q if the container runs with a terminal), the container keeps running")
+	attachCmd := exec.Command("docker", "attach", "--sig-proxy=false", id)
+	attachCmd.Stdin, attachCmd.Stdout, attachCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	log.Debug(strings.Join(attachCmd.Args, " "))
+	if err := attachCmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		log.Error(err)
+		return 1
+	}
+	return 0
+}
+
+// stopContainers interrupts the specified tgf containers, they are killed if they are still running after the stop timeout
+func stopContainers(names ...string) (exitCode int) {
+	cli, ctx := getDockerClient()
+	timeout := containerStopTimeout
+	for _, name := range names {
+		id, err := checkContainer(name)
+		if err != nil {
+			log.Error(err)
+			exitCode = 1
+			continue
+		}
+		log.Infof("Stopping container %s (waiting up to %ds for the command to exit)", name, timeout)
+		if err := cli.ContainerStop(ctx, id, container.StopOptions{Signal: "SIGINT", Timeout: &timeout}); err != nil {
+			log.Errorf("Unable to stop container %s: %v", name, err)
+			exitCode = 1
+			continue
+		}
+		log.Info("Stopped container ", name)
+	}
+	return
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetContainerLabels(t *testing.T) {
+	t.Parallel()
+
+	labels := getContainerLabels("/home/jsmith/project", "coveo/tgf:1.2.3", []string{"terragrunt", "plan"})
+	assert.Equal(t, version, labels[containerLabelVersion])
+	assert.Equal(t, "/home/jsmith/project", labels[containerLabelFolder])
+	assert.Equal(t, "terragrunt plan", labels[containerLabelCommand])
+	assert.Equal(t, "coveo/tgf:1.2.3", labels[containerLabelImage])
+	started, err := time.Parse(time.RFC3339, labels[containerLabelStarted])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), started, time.Minute)
+}
+
+func TestGetLabelArgs(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		[]string{"--label", "tgf.command=terragrunt plan", "--label", "tgf.version=1.2.3"},
+		getLabelArgs(map[string]string{"tgf.version": "1.2.3", "tgf.command": "terragrunt plan"}),
+	)
+	assert.Nil(t, getLabelArgs(nil))
+}
+
+func TestContainerFlags(t *testing.T) {
+	t.Parallel()
+
+	app := NewTestApplication([]string{"--lc", "--ac", "abc", "--sc", "def", "--stop-container", "ghi"}, false)
+	assert.True(t, app.ListContainers)
+	assert.Equal(t, "abc", app.AttachContainer)
+	assert.Equal(t, []string{"def", "ghi"}, app.StopContainers)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+const (
+	passwdFile         = "/etc/passwd"
+	groupFile          = "/etc/group"
+	volumeOwnerPrefix  = "owner:"
+	minimalPasswd      = "root:x:0:0:root:/root:/bin/sh\n"
+	minimalGroup       = "root:x:0:\n"
+	currentUserPattern = "tgf-current-user"
+)
+
+// getCurrentUserMounts returns the mounts of the passwd and group files of the image completed with the entries of the current
+// user, so tools calling getpwuid find the user running in the container. The returned function must be called once the
+// container is terminated.
+func getCurrentUserMounts(imageName string, currentUser *user.User, home string) (mounts []TGFConfigMount, cleanup func()) {
+	var files []string
+	cleanup = func() {
+		for _, file := range files {
+			os.Remove(file)
+		}
+	}
+
+	username := getUsername(currentUser.Username)
+	groupName := username
+	if group, err := user.LookupGroupId(currentUser.Gid); err == nil {
+		groupName = group.Name
+	}
+
+	for _, entry := range []struct {
+		target, fallback string
+		generate         func(string) string
+	}{
+		{passwdFile, minimalPasswd, func(content string) string {
+			return generatePasswd(content, username, currentUser.Uid, currentUser.Gid, home)
+		}},
+		{groupFile, minimalGroup, func(content string) string {
+			return generateGroup(content, groupName, currentUser.Gid)
+		}},
+	} {
+		content, err := readImageFile(imageName, entry.target)
+		if err != nil {
+			log.Debugf("Unable to read %s from %s, using a minimal file: %v", entry.target, imageName, err)
+			content = entry.fallback
+		}
+		file := must(os.CreateTemp("", currentUserPattern)).(*os.File)
+		files = append(files, file.Name())
+		must(fmt.Fprint(file, entry.generate(content)))
+		must(file.Close())
+		// The file must be readable by the user in the container
+		must(os.Chmod(file.Name(), 0644))
+		mounts = append(mounts, TGFConfigMount{Source: file.Name(), Target: entry.target, ReadOnly: true})
+	}
+	return
+}
+
+// generatePasswd returns the passwd content with the entry of the user, replacing any entry having the same name or uid
+func generatePasswd(content, username, uid, gid, home string) string {
+	entry := fmt.Sprintf("%s:x:%s:%s:%s:%s:/bin/sh", username, uid, gid, username, home)
+	return replaceEntry(content, entry, func(fields []string) bool {
+		return len(fields) > 2 && (fields[0] == username || fields[2] == uid)
+	})
+}
+
+// generateGroup returns the group content with the group of the user, unless the gid is already defined
+func generateGroup(content, groupName, gid string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Split(line, ":"); len(fields) > 2 && fields[2] == gid {
+			return content
+		}
+	}
+	entry := fmt.Sprintf("%s:x:%s:", groupName, gid)
+	return replaceEntry(content, entry, func(fields []string) bool { return fields[0] == groupName })
+}
+
+func replaceEntry(content, entry string, match func(fields []string) bool) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		if line != "" && !match(strings.Split(line, ":")) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(append(lines, entry), "\n") + "\n"
+}
+
+// getUsername returns the user name without the Windows domain (e.g. ACME\jsmith) since the backslash is not accepted in volume names
+func getUsername(username string) string {
+	split := strings.Split(username, "\\")
+	return split[len(split)-1]
+}
+
+// getImageOwner returns the uid[:gid] of the user running the image, the user and group names are resolved with the passwd and
+// group files of the image. It is empty if the image runs as root or as the current user, the files of tgf are then readable as is.
+func getImageOwner(imageName, imageUser string) (string, error) {
+	uid, gid, _ := strings.Cut(imageUser, ":")
+	if uid == "root" {
+		return "", nil
+	}
+	if _, err := strconv.Atoi(uid); err != nil && uid != "" {
+		content, err := readImageFile(imageName, passwdFile)
+		if err != nil {
+			return "", err
+		}
+		name, primaryGroup := uid, ""
+		if uid, primaryGroup = lookupEntryIDs(content, name); uid == "" {
+			return "", fmt.Errorf("user %s is not defined in %s", name, passwdFile)
+		}
+		if gid == "" {
+			gid = primaryGroup
+		}
+	}
+	if _, err := strconv.Atoi(gid); err != nil && gid != "" {
+		content, err := readImageFile(imageName, groupFile)
+		if err != nil {
+			return "", err
+		}
+		name := gid
+		if gid, _ = lookupEntryIDs(content, name); gid == "" {
+			return "", fmt.Errorf("group %s is not defined in %s", name, groupFile)
+		}
+	}
+	if uid == "" || uid == "0" || uid == strconv.Itoa(os.Getuid()) {
+		return "", nil
+	}
+	if gid != "" {
+		return uid + ":" + gid, nil
+	}
+	return uid, nil
+}
+
+// lookupEntryIDs returns the id (and the group id for a passwd file) of the named entry in a passwd or group file
+func lookupEntryIDs(content, name string) (id, gid string) {
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Split(line, ":"); len(fields) > 2 && fields[0] == name {
+			if len(fields) > 3 {
+				gid = fields[3]
+			}
+			return fields[2], gid
+		}
+	}
+	return "", ""
+}
+
+// readImageFile returns the content of a file in the image
+func readImageFile(imageName, file string) (content string, err error) {
+	err = withContainer(&container.Config{Image: imageName}, nil, func(id string) error {
+		cli, ctx := getDockerClient()
+		reader, _, err := cli.CopyFromContainer(ctx, id, file)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		archive := tar.NewReader(reader)
+		if _, err := archive.Next(); err != nil {
+			return err
+		}
+		bytes, err := io.ReadAll(archive)
+		content = string(bytes)
+		return err
+	})
+	return
+}
+
+// alignVolumeOwnership ensures that the volume belongs to the user. The ownership is only changed once for each volume
+// (a marker is kept until the volume is recreated).
+func alignVolumeOwnership(name, imageName, uid, gid string) {
+	cli, ctx := getDockerClient()
+	vol, err := cli.VolumeInspect(ctx, name)
+	if errdefs.IsNotFound(err) {
+		vol, err = cli.VolumeCreate(ctx, volume.CreateOptions{Name: name})
+	}
+	if err != nil {
+		log.Warningf("Unable to get volume %s: %v", name, err)
+		return
+	}
+
+	marker := fmt.Sprintf("%s%s:%s:%s", volumeOwnerPrefix, name, uid, gid)
+	if created, err := time.Parse(time.RFC3339, vol.CreatedAt); err == nil && getLastRefresh(marker).After(created) {
+		return
+	}
+
+	log.Debugf("Changing the owner of volume %s to %s:%s", name, uid, gid)
+	err = withVolumeContainer(name, &container.Config{
+		Image:      imageName,
+		User:       "0",
+		Entrypoint: []string{"chown"},
+		Cmd:        []string{"-R", fmt.Sprintf("%s:%s", uid, gid), volumeMountPath},
+	}, func(id string) error {
+		if err := cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+			return err
+		}
+		statusCh, errCh := cli.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+		select {
+		case err := <-errCh:
+			return err
+		case status := <-statusCh:
+			if status.StatusCode != 0 {
+				return fmt.Errorf("chown exited with code %d", status.StatusCode)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warningf("Unable to change the owner of volume %s: %v", name, err)
+		return
+	}
+	touchImageRefresh(marker)
+}
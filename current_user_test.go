@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratePasswd(t *testing.T) {
+	t.Parallel()
+
+	content := "root:x:0:0:root:/root:/bin/bash\nubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash\n"
+	assert.Equal(t,
+		"root:x:0:0:root:/root:/bin/bash\njsmith:x:1000:1000:jsmith:/home/jsmith:/bin/sh\n",
+		generatePasswd(content, "jsmith", "1000", "1000", "/home/jsmith"))
+	assert.Equal(t,
+		"root:x:0:0:root:/root:/bin/bash\nubuntu:x:1000:1000:Ubuntu:/home/ubuntu:/bin/bash\njsmith:x:501:20:jsmith:/home/jsmith:/bin/sh\n",
+		generatePasswd(content, "jsmith", "501", "20", "/home/jsmith"))
+	assert.Equal(t,
+		"root:x:0:0:root:/root:/bin/sh\njsmith:x:501:20:jsmith:/home/jsmith:/bin/sh\n",
+		generatePasswd(minimalPasswd, "jsmith", "501", "20", "/home/jsmith"))
+}
+
+func TestGenerateGroup(t *testing.T) {
+	t.Parallel()
+
+	content := "root:x:0:\nubuntu:x:1000:\n"
+	assert.Equal(t, content, generateGroup(content, "jsmith", "1000"))
+	assert.Equal(t, "root:x:0:\nubuntu:x:1000:\nstaff:x:20:\n", generateGroup(content, "staff", "20"))
+}
+
+func TestGetUsername(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "jsmith", getUsername(`ACME\jsmith`))
+	assert.Equal(t, "jsmith", getUsername("jsmith"))
+}
+
+func TestLookupEntryIDs(t *testing.T) {
+	t.Parallel()
+
+	uid, gid := lookupEntryIDs("root:x:0:0:root:/root:/bin/bash\nubuntu:x:1000:1001:Ubuntu:/home/ubuntu:/bin/bash\n", "ubuntu")
+	assert.Equal(t, "1000", uid)
+	assert.Equal(t, "1001", gid)
+	gid, _ = lookupEntryIDs("root:x:0:\nstaff:x:20:\n", "staff")
+	assert.Equal(t, "20", gid)
+	uid, _ = lookupEntryIDs(minimalPasswd, "ubuntu")
+	assert.Empty(t, uid)
+}
+
+func TestGetImageOwner(t *testing.T) {
+	t.Parallel()
+
+	// The numeric users are used as is without reading the image
+	for imageUser, expected := range map[string]string{
+		"":          "",
+		"root":      "",
+		"0:0":       "",
+		"1234":      "1234",
+		"1234:5678": "1234:5678",
+	} {
+		owner, err := getImageOwner("", imageUser)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, owner, imageUser)
+	}
+}
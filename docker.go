@@ -5,17 +5,18 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"os/user"
-	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
@@ -44,6 +45,96 @@ const (
 type dockerConfig struct{ *TGFConfig }
 
 func (docker *dockerConfig) call() int {
+	app, config := docker.tgf, docker.TGFConfig
+	imageName := docker.getImage()
+
+	if app.GetImageName {
+		fmt.Println(imageName)
+		return 0
+	}
+
+	var command []string
+	if app.Shell {
+		var shellMounts []TGFConfigMount
+		var shellCleanup func()
+		command, shellMounts, shellCleanup = docker.getShellCommand(imageName)
+		defer shellCleanup()
+		config.Mounts = append(config.Mounts, shellMounts...)
+		config.EntryPoint = command[0]
+		// A shell is always interactive
+		app.DockerInteractive, app.DockerInteractiveSet = true, true
+	} else {
+		command = docker.getCommand()
+	}
+
+	if config.LockFolder && !app.DryRun {
+		// Prevent concurrent runs in the same folder (e.g. two applies on the same stack)
+		release, _ := acquireLock(getFolderLockName(must(filepath.EvalSymlinks(must(os.Getwd()).(string))).(string)))
+		defer release()
+	}
+
+	run := docker.prepareRun(imageName, command)
+	defer run.cleanup()
+
+	if app.Export {
+		return docker.export(run)
+	}
+	if app.DryRun {
+		return run.print(app.DryRunFormat)
+	}
+
+	// The signals are handled until the end of the run, so the temporary files are removed and the after run actions are executed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	dockerCmd := exec.Command("docker", run.Args...)
+	dockerCmd.Stdin, dockerCmd.Stdout = os.Stdin, os.Stdout
+	var stderr bytes.Buffer
+	dockerCmd.Stderr = &stderr
+
+	log.Debug(color.HiBlackString(strings.Join(dockerCmd.Args, " ")))
+
+	if err := runWithSignals(dockerCmd, signals); err != nil {
+		if stderr.Len() > 0 {
+			log.Errorf("%s\n%s %s", stderr.String(), dockerCmd.Args[0], strings.Join(run.Args, " "))
+			if runtime.GOOS == "windows" {
+				log.Error(windowsMessage)
+			}
+		}
+	}
+	for _, after := range run.after {
+		after()
+	}
+	return dockerCmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+}
+
+// runWithSignals runs the command and waits for it even if tgf is interrupted. The interrupt signal (Ctrl-C) is already received by
+// docker since it belongs to the same process group, the other signals are forwarded to docker that stops the container.
+func runWithSignals(cmd *exec.Cmd, signals <-chan os.Signal) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case sig := <-signals:
+			if sig == os.Interrupt {
+				continue
+			}
+			log.Debugf("Forwarding %v to docker", sig)
+			if err := cmd.Process.Signal(sig); err != nil {
+				log.Debugf("Unable to forward %v to docker: %v", sig, err)
+			}
+		}
+	}
+}
+
+// getCommand returns the command to run in the container, the terragrunt specific arguments are added if required
+func (docker *dockerConfig) getCommand() []string {
 	app, config := docker.tgf, docker.TGFConfig
 	args := app.Unmanaged
 	command := append(strings.Split(config.EntryPoint, " "), args...)
@@ -73,26 +164,87 @@ func (docker *dockerConfig) call() int {
 	if app.FlushCache && filepath.Base(config.EntryPoint) == "terragrunt" {
 		command = append(command, "--terragrunt-source-update")
 	}
+	return command
+}
 
-	imageName := docker.getImage()
-
-	if app.GetImageName {
-		fmt.Println(imageName)
-		return 0
-	}
+// prepareRun computes the docker run invocation (mounts, environment and arguments) without running it
+func (docker *dockerConfig) prepareRun(imageName string, command []string) *dockerRun {
+	app, config := docker.tgf, docker.TGFConfig
+	run := &dockerRun{Image: imageName, Command: command}
+	dryRun := app.DryRun
 
 	cwd := filepath.ToSlash(must(filepath.EvalSymlinks(must(os.Getwd()).(string))).(string))
-	currentDrive := fmt.Sprintf("%s/", filepath.VolumeName(cwd))
-	rootFolder := strings.Split(strings.TrimPrefix(cwd, currentDrive), "/")[0]
-	sourceFolder := fmt.Sprintf("/%s", filepath.ToSlash(strings.Replace(strings.TrimPrefix(cwd, currentDrive), rootFolder, app.MountPoint, 1)))
+	mountScope := config.MountScope
+	remote := config.useRemoteSync()
+	if remote {
+		log.Debug("The docker host is remote, the sources are synchronized in a volume")
+		if mountScope == "" {
+			// Avoid synchronizing the whole first folder of the path
+			mountScope = mountScopeGitRoot
+		}
+		if app.MountHomeDir {
+			log.Warning("The home folder cannot be mounted with a remote docker host")
+			app.MountHomeDir = false
+		}
+		if app.TempDirMountLocation == mountLocHost {
+			log.Warningf("The temp folder cannot be mounted with a remote docker host, using the %s volume", dockerVolumeName)
+			app.TempDirMountLocation = mountLocVolume
+		}
+		if config.SecretsAsFiles {
+			log.Warning("The secrets cannot be delivered as files with a remote docker host")
+			config.SecretsAsFiles = false
+		}
+	}
+	if dryRun {
+		if config.SecretsAsFiles {
+			run.Notes = append(run.Notes, "The secrets are delivered as environment variables instead of files")
+			config.SecretsAsFiles = false
+		}
+		if len(config.GitCredentialHosts) > 0 {
+			run.Notes = append(run.Notes, fmt.Sprintf("The git credentials of %s are not included", strings.Join(config.GitCredentialHosts, ", ")))
+			config.GitCredentialHosts = nil
+		}
+	}
+	mountRoot, err := getMountRoot(cwd, mountScope)
+	if err != nil {
+		if mountScope != mountScopeGitRoot {
+			panic(errors.Managed(err.Error()))
+		}
+		log.Warningf("%v, only the current folder is mounted", err)
+		mountRoot = cwd
+	}
+	var relativeSources []string
+	// The relative sources are only referenced by the terragrunt configuration files
+	if config.MountRelativeSources && filepath.Base(config.EntryPoint) == "terragrunt" {
+		relativeSources = findRelativeSources(cwd)
+	}
+	containerRoot, sourceFolder := getMountFolders(cwd, mountRoot, app.MountPoint, getParentLevels(relativeSources, mountRoot))
+	run.WorkDir = sourceFolder
+	sourcesMount := TGFConfigMount{Source: mountRoot, Target: containerRoot, ReadOnly: config.MountReadOnly}
+	if remote {
+		sourcesMount.Type, sourcesMount.Source = mountTypeVolume, getSourcesVolume(mountRoot)
+		if dryRun {
+			run.Notes = append(run.Notes, fmt.Sprintf("%s must be synchronized to volume %s before running the script", mountRoot, sourcesMount.Source))
+		} else {
+			// The volume holds the sources of the folder, the concurrent runs must wait until the files are synchronized back
+			release, _ := acquireLock(getVolumeLockName(sourcesMount.Source))
+			run.cleanups = append(run.cleanups, release)
+			syncSources(mountRoot, imageName)
+			if !config.MountReadOnly {
+				run.after = append(run.after, func() {
+					syncBack(sourcesMount.Source, imageName, strings.TrimPrefix(sourceFolder, containerRoot), cwd, config.RemoteSyncBack)
+				})
+			}
+		}
+	}
+	run.sources = sourcesMount
+	mounts := []TGFConfigMount{sourcesMount}
 
 	dockerArgs := []string{
 		"run",
 	}
-	if app.DockerInteractive {
-		dockerArgs = append(dockerArgs, "-it")
-	}
-	dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s%s:/%s", convertDrive(currentDrive), rootFolder, app.MountPoint), "-w", sourceFolder)
+	dockerArgs = append(dockerArgs, app.getInteractiveArgs()...)
+	dockerArgs = append(dockerArgs, "-w", sourceFolder)
 
 	if app.WithDockerMount {
 		withDockerMountArgs := getDockerMountArgs()
@@ -100,64 +252,90 @@ func (docker *dockerConfig) call() int {
 	}
 
 	// No need to map to current user on windows. Files written by docker containers in windows seem to be accessible by the user calling docker
-	if app.WithCurrentUser && runtime.GOOS != "windows" {
-		currentUser := must(user.Current()).(*user.User)
+	currentUser := must(user.Current()).(*user.User)
+	withCurrentUser := app.WithCurrentUser && runtime.GOOS != "windows"
+	if withCurrentUser {
 		dockerArgs = append(dockerArgs, fmt.Sprintf("--user=%s:%s", currentUser.Uid, currentUser.Gid))
 	}
 
-	currentUser := must(user.Current()).(*user.User)
+	var containerHome string
 	if app.MountHomeDir {
 		home := filepath.ToSlash(currentUser.HomeDir)
 		mountingHome := fmt.Sprintf("/home/%s", filepath.Base(home))
+		containerHome = mountingHome
 
-		dockerArgs = append(dockerArgs, []string{
-			"-v", fmt.Sprintf("%v:%v", convertDrive(home), mountingHome),
-			"-e", fmt.Sprintf("HOME=%v", mountingHome),
-		}...)
+		mounts = append(mounts, TGFConfigMount{Source: currentUser.HomeDir, Target: mountingHome})
+		dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("HOME=%v", mountingHome))
 	} else if app.TempDirMountLocation != mountLocNone {
 		// If temp location is not disabled, we persist the home folder in a docker volume
-		imageSummary := getImageSummary(imageName)
-		if imageSummary == nil {
-			panic(errors.Managed(fmt.Sprintf("Unable to load image %v", imageName)))
-		}
-		image := inspectImage(imageSummary.ID)
 		username := currentUser.Username
-
-		if image.Config.User != "" {
-			// If an explicit user is defined in the image, we use that user instead of the actual one
-			// This ensure to not mount a folder with no permission to write into it
-			username = image.Config.User
+		if imageSummary := getImageSummary(imageName); imageSummary != nil {
+			image := inspectImage(imageSummary.ID)
+			if image.Config.User != "" && !withCurrentUser {
+				// If an explicit user is defined in the image, we use that user instead of the actual one
+				// This ensure to not mount a folder with no permission to write into it
+				username = image.Config.User
+			}
+		} else if !dryRun {
+			panic(errors.Managed(fmt.Sprintf("Unable to load image %v", imageName)))
 		}
 
 		// Fix for Windows containing the domain name in the Username (e.g. ACME\jsmith)
 		// The backslash is not accepted for a Docker volume path
-		splitUsername := strings.Split(username, "\\")
-		username = splitUsername[len(splitUsername)-1]
+		username = getUsername(username)
 
 		homePath := fmt.Sprintf("/home/%s", username)
-		dockerArgs = append(dockerArgs,
-			"-e", fmt.Sprintf("HOME=%s", homePath),
-			"-v", fmt.Sprintf("%s-%s:%s", dockerVolumeName, username, homePath),
-		)
+		homeVolume := fmt.Sprintf("%s-%s", dockerVolumeName, username)
+		containerHome = homePath
+		if withCurrentUser && !dryRun {
+			// The volume must be writable by the current user
+			alignVolumeOwnership(homeVolume, imageName, currentUser.Uid, currentUser.Gid)
+		}
+		dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("HOME=%s", homePath))
+		mounts = append(mounts, TGFConfigMount{Source: homeVolume, Target: homePath, Type: mountTypeVolume})
+		touchLastUse(homeVolume)
 	}
 
+	dockerArgs = append(dockerArgs, config.getResourceArgs()...)
+	dockerArgs = append(dockerArgs, config.getPlatformArgs()...)
 	dockerArgs = append(dockerArgs, config.DockerOptions...)
+	if relativeMounts := getRelativeSourceMounts(relativeSources, mountRoot, containerRoot, config.MountReadOnly); len(relativeMounts) > 0 {
+		log.Debugf("Mounting the folders referenced by the terragrunt configuration outside %s\n%s", mountRoot, color.HiBlackString(String(formatMounts(relativeMounts)).IndentN(4).Str()))
+		mounts = append(mounts, relativeMounts...)
+	}
+	mounts = append(mounts, config.getHomeMounts(containerHome)...)
+	imageOwner := docker.getSecretsOwner(imageName, withCurrentUser, dryRun)
+	forwardMounts, forwardCleanup := docker.getForwardMounts(containerHome, imageOwner)
+	run.cleanups = append(run.cleanups, forwardCleanup)
+	mounts = append(mounts, forwardMounts...)
+	if withCurrentUser {
+		if dryRun {
+			run.Notes = append(run.Notes, "The passwd and group files matching the current user are not included")
+		} else {
+			userHome := containerHome
+			if userHome == "" {
+				userHome = fmt.Sprintf("/home/%s", getUsername(currentUser.Username))
+			}
+			userMounts, userCleanup := getCurrentUserMounts(imageName, currentUser, userHome)
+			run.cleanups = append(run.cleanups, userCleanup)
+			mounts = append(mounts, userMounts...)
+		}
+	}
+	mounts = append(mounts, config.Mounts...)
 
 	switch app.TempDirMountLocation {
 	case mountLocHost:
 		temp := filepath.ToSlash(filepath.Join(must(filepath.EvalSymlinks(os.TempDir())).(string), "tgf-cache"))
-		tempDrive := fmt.Sprintf("%s/", filepath.VolumeName(temp))
-		tempFolder := strings.TrimPrefix(temp, tempDrive)
-		if runtime.GOOS == "windows" {
-			os.Mkdir(temp, 0755)
-		}
-		dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s%s:%s", convertDrive(tempDrive), tempFolder, dockerMountImagePath))
-		config.Environment["TGF_TEMP_FOLDER"] = path.Join(tempDrive, tempFolder)
+		// The folder must exist to be mounted
+		os.MkdirAll(temp, 0755)
+		mounts = append(mounts, TGFConfigMount{Source: temp, Target: dockerMountImagePath})
+		config.Environment["TGF_TEMP_FOLDER"] = temp
 	case mountLocNone:
 		// Nothing to do
 	case mountLocVolume:
-		// docker's -v option will automatically create the volume if it doesn't already exist
-		dockerArgs = append(dockerArgs, "-v", fmt.Sprintf("%s:%s", dockerVolumeName, dockerMountImagePath))
+		// docker will automatically create the volume if it doesn't already exist
+		mounts = append(mounts, TGFConfigMount{Source: dockerVolumeName, Target: dockerMountImagePath, Type: mountTypeVolume})
+		touchLastUse(dockerVolumeName)
 	default:
 		// We added a mount location and forgot to handle it...
 		panic(fmt.Sprintf("Unknown mount location '%s'.  Please report a bug.", app.TempDirMountLocation))
@@ -184,6 +362,16 @@ func (docker *dockerConfig) call() int {
 		}
 	}
 
+	secretMounts, secretCleanup := config.getSecretMounts(imageOwner)
+	run.cleanups = append(run.cleanups, secretCleanup)
+	mounts = append(mounts, secretMounts...)
+
+	if remote {
+		mounts = filterBindMounts(mounts)
+	}
+	run.Mounts = resolveMounts(mounts...)
+	dockerArgs = append(dockerArgs, getMountArgs(run.Mounts...)...)
+
 	if len(config.Environment) > 0 {
 		for key, val := range config.Environment {
 			os.Setenv(key, val)
@@ -191,7 +379,7 @@ func (docker *dockerConfig) call() int {
 		if log.GetLevel() >= logrus.DebugLevel {
 			exportedVariables := make(collections.StringArray, len(config.Environment))
 			for i, key := range collections.AsDictionary(config.Environment).KeysAsString() {
-				if key == "AWS_SECRET_ACCESS_KEY" || key == "AWS_SESSION_TOKEN" {
+				if config.isSecretVariable(key.String()) {
 					exportedVariables[i] = String(fmt.Sprintf("%s = ******", key))
 				} else {
 					exportedVariables[i] = String(fmt.Sprintf("%s = %s", key, config.Environment[key.String()]))
@@ -205,30 +393,43 @@ func (docker *dockerConfig) call() int {
 		dockerArgs = append(dockerArgs, strings.Split(do, " ")...)
 	}
 
+	dockerArgs = append(dockerArgs, getLabelArgs(getContainerLabels(cwd, imageName, command))...)
+
 	if !listContainsElement(dockerArgs, "--name") {
 		// We do not remove the image after execution if a name has been provided
 		dockerArgs = append(dockerArgs, "--rm")
 	}
 
-	dockerArgs = append(dockerArgs, getEnviron(app.MountHomeDir)...)
+	environ := getEnviron(app.MountHomeDir)
+	for i := 1; i < len(environ); i += 2 {
+		run.Environment = append(run.Environment, environ[i])
+	}
+	run.exported = config.Environment
+	run.isSecret = config.isSecretVariable
+	dockerArgs = append(dockerArgs, environ...)
 	dockerArgs = append(dockerArgs, imageName)
 	dockerArgs = append(dockerArgs, command...)
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	dockerCmd.Stdin, dockerCmd.Stdout = os.Stdin, os.Stdout
-	var stderr bytes.Buffer
-	dockerCmd.Stderr = &stderr
-
-	log.Debug(color.HiBlackString(strings.Join(dockerCmd.Args, " ")))
+	run.Args = dockerArgs
+	return run
+}
 
-	if err := dockerCmd.Run(); err != nil {
-		if stderr.Len() > 0 {
-			log.Errorf("%s\n%s %s", stderr.String(), dockerCmd.Args[0], strings.Join(dockerArgs, " "))
-			if runtime.GOOS == "windows" {
-				log.Error(windowsMessage)
-			}
-		}
+// getSecretsOwner returns the user of the image (uid[:gid]) that must receive the secret files (secrets and git credentials) written
+// by tgf, empty if the files are readable as is. If the user cannot be resolved, its name is returned as is and the files are not delivered.
+func (docker *dockerConfig) getSecretsOwner(imageName string, withCurrentUser, dryRun bool) string {
+	if withCurrentUser || runtime.GOOS != "linux" || dryRun || (!docker.SecretsAsFiles && len(docker.GitCredentialHosts) == 0) {
+		return ""
 	}
-	return dockerCmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
+	imageSummary := getImageSummary(imageName)
+	if imageSummary == nil {
+		return ""
+	}
+	imageUser := inspectImage(imageSummary.ID).Config.User
+	owner, err := getImageOwner(imageName, imageUser)
+	if err != nil {
+		log.Debugf("Unable to get the uid of user %s in %s: %v", imageUser, imageName, err)
+		return imageUser
+	}
+	return owner
 }
 
 // Returns the image name to use
@@ -246,6 +447,13 @@ func (docker *dockerConfig) getImage() (name string) {
 
 	lastHash := ""
 	for i, ib := range docker.imageBuildConfigs {
+		baseName := name
+		name, lastHash = getBuildImageName(name, lastHash, docker.Platform, ib)
+		if app.DryRun {
+			// The name of the image is known, but it is not built
+			continue
+		}
+
 		var temp, folder, dockerFile string
 		var out *os.File
 		if ib.Folder == "" {
@@ -266,7 +474,7 @@ func (docker *dockerConfig) getImage() (name string) {
 
 		if out != nil {
 			log.Debug("Writing instructions to dockerfile")
-			ib.Instructions = fmt.Sprintf("FROM %s\n%s\n", name, ib.Instructions)
+			ib.Instructions = fmt.Sprintf("FROM %s\n%s\n", baseName, ib.Instructions)
 			must(fmt.Fprint(out, ib.Instructions))
 			must(out.Close())
 		}
@@ -285,18 +493,18 @@ func (docker *dockerConfig) getImage() (name string) {
 			}()
 		}
 
-		// We remove the last hash from the name to avoid cumulating several hash in the final name
-		name = strings.Replace(name, lastHash, "", 1)
-		lastHash = fmt.Sprintf("-%s", ib.hash())
-
-		name = name + "-" + ib.GetTag()
-		if image, tag := collections.Split2(name, ":"); len(tag) > maxDockerTagLength {
-			name = image + ":" + tag[0:maxDockerTagLength]
-		}
-		if app.Refresh || getImageHash(name) != ib.hash() {
+		release, waited := acquireLock(getImageLockName(name))
+		// The image does not have to be rebuilt if another process has just built it while we were waiting for the lock
+		if app.Refresh && !waited || getImageHash(name) != ib.hash() {
 			label := fmt.Sprintf("hash=%s", ib.hash())
 			args := []string{"build", ".", "-f", dockerfilePattern, "--quiet", "--force-rm", "--label", label}
-			if i == 0 && app.Refresh && !app.UseLocalImage {
+			effectiveDockerfile := filepath.Join(folder, dockerfilePattern)
+			if dockerFile != "" {
+				effectiveDockerfile = dockerFile
+			}
+			// The images requiring registry-auth credentials are pulled by tgf since docker build does not know them
+			pulled := docker.pullBuildImages(effectiveDockerfile)
+			if i == 0 && app.Refresh && !app.UseLocalImage && !app.Offline && !pulled {
 				args = append(args, "--pull")
 			}
 			if dockerFile != "" {
@@ -304,6 +512,7 @@ func (docker *dockerConfig) getImage() (name string) {
 				args = append(args, filepath.Base(dockerFile))
 			}
 
+			args = append(args, docker.getPlatformArgs()...)
 			args = append(args, "--tag", name)
 			buildCmd := exec.Command("docker", args...)
 
@@ -317,11 +526,41 @@ func (docker *dockerConfig) getImage() (name string) {
 			must(buildCmd.Output())
 			pruneDangling()
 		}
+		release()
 	}
 
+	if len(docker.imageBuildConfigs) > 0 && !app.DryRun {
+		// Keep track of the built image usage to avoid cleaning up images that are still in use
+		touchLastUse(name)
+	}
 	return
 }
 
+// getBuildImageName returns the name of the image built from the previous image with the build config
+// The platform is added at the end of the tag to avoid collisions between the images built for different platforms
+func getBuildImageName(name, lastHash, platform string, ib TGFConfigBuild) (string, string) {
+	if image, digest := collections.Split2(name, "@"); digest != "" {
+		// A reference pinned by digest cannot be extended with a tag, so we use the short digest as the base tag
+		name = image + ":" + strings.Replace(shortDigest(digest), ":", "-", 1)
+	}
+
+	platformTag := getPlatformTag(platform)
+	if lastHash != "" {
+		// The name comes from a previous build, so it already ends with the platform
+		name = strings.TrimSuffix(name, platformTag)
+	}
+
+	// We remove the last hash from the name to avoid cumulating several hash in the final name
+	name = strings.Replace(name, lastHash, "", 1)
+	lastHash = fmt.Sprintf("-%s", ib.hash())
+
+	name = name + "-" + ib.GetTag()
+	if image, tag := collections.Split2(name, ":"); len(tag)+len(platformTag) > maxDockerTagLength {
+		name = image + ":" + tag[0:maxDockerTagLength-len(platformTag)]
+	}
+	return name + platformTag, lastHash
+}
+
 var pruneDangling = func() {
 	cli, ctx := getDockerClient()
 	danglingFilters := filters.NewArgs()
@@ -408,7 +647,22 @@ func validateDockerVersion(version string) (bool, error) {
 
 func getDockerClient() (*client.Client, context.Context) {
 	if dockerClient == nil {
-		dockerClient = must(client.NewClientWithOpts(client.FromEnv)).(*client.Client)
+		options := []client.Opt{client.FromEnv}
+		if endpoint := getDockerEndpoint(); strings.HasPrefix(endpoint.Host, "ssh://") {
+			// The host is only used to build the requests, the connection is made through ssh
+			options = append(options, client.WithHost("http://docker.example.com"), client.WithDialContext(sshDialer(endpoint.Host)))
+		} else if endpoint.Host != "" {
+			tlsConfig, err := endpoint.getTLSConfig()
+			if err != nil {
+				panic(errors.Managed(fmt.Sprintf("Unable to load the TLS certificates of the docker context: %v", err)))
+			}
+			if tlsConfig != nil {
+				// The transport must be defined before the host since the host configures it
+				options = append(options, client.WithHTTPClient(&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}))
+			}
+			options = append(options, client.WithHost(endpoint.Host))
+		}
+		dockerClient = must(client.NewClientWithOpts(options...)).(*client.Client)
 		dockerContext = context.Background()
 	}
 
@@ -491,6 +745,17 @@ var reECR = regexp.MustCompile(`(?P<account>[0-9]+)\.dkr\.ecr\.(?P<region>[a-z0-
 
 func (docker *dockerConfig) refreshImage(image string) {
 	app := docker.tgf
+	if app.Offline {
+		if !checkImage(image) {
+			panic(errors.Managed(fmt.Sprintf("The image %s is not available locally, it cannot be pulled in offline mode", image)))
+		}
+		log.Debugf("Not refreshing %v because the offline mode is enabled", image)
+		return
+	}
+	if docker.ImageDigest != "" && checkImage(image) && docker.hasExpectedPlatform(image) {
+		log.Debugf("Not refreshing %v because it is pinned by digest and already available locally", image)
+		return
+	}
 	app.Refresh = true // Setting this to true will ensure that dependant built images will also be refreshed
 
 	if app.UseLocalImage {
@@ -498,30 +763,59 @@ func (docker *dockerConfig) refreshImage(image string) {
 		return
 	}
 
-	log.Debugln("Checking if there is a newer version of docker image", image)
+	start := time.Now()
+	release, waited := acquireLock(getImageLockName(image))
+	defer release()
+	if waited && getLastRefresh(image).After(start) {
+		log.Debugf("Not refreshing %v because it has been refreshed by another process", image)
+		return
+	}
 
-	for try := 0; try < 2; try++ {
-		var stderr bytes.Buffer
-		cmd := getDockerUpdateCmd(image)
-		cmd.Stderr = &stderr
-		if err := cmd.Run(); err == nil {
-			break
-		} else if try == 0 && docker.awsConfigExist() {
-			log.Debugf("Failed to pull %v. It is an ECR image, trying again after login to AWS ECR.", image)
-			if err = docker.tryLoginToECR(image); err == nil {
-				continue
-			} else {
-				panic(err)
-			}
-		} else if stderr.Len() > 0 {
-			panic(errors.Managed(stderr.String()))
-		} else {
-			panic(err)
+	log.Debugln("Checking if there is a newer version of docker image", image)
+	localDigests := getLocalDigests(image)
+	if !docker.hasExpectedPlatform(image) {
+		// The digest refers to all the platforms of the image, so it cannot tell that the local image is for another platform
+		localDigests = nil
+	}
+	if docker.isImageUpToDate(image, localDigests) {
+		touchImageRefresh(image)
+		return
+	}
+	if err := docker.pullImage(image); err != nil {
+		if (isTransientPullError(err) || isPullAuthError(err)) && checkImage(image) {
+			// The refresh will be attempted again on the next run, expired credentials must not prevent the use of the local image
+			log.Warningf("Unable to refresh %s (%v), using the local image", image, err)
+			return
 		}
+		panic(errors.Managed(fmt.Sprintf("Unable to pull %s: %v", image, err)))
 	}
+	reportImageUpdate(image, localDigests, getLocalDigests(image))
 	touchImageRefresh(image)
 }
 
+// The ECR tokens are renewed a few minutes before their expiry to avoid an expiry during the pull
+const (
+	ecrTokenPrefix       = "ecr-token:"
+	ecrTokenExpiryMargin = 5 * time.Minute
+)
+
+// useECRLogin returns true if the credentials of the image registry are obtained through an ECR login
+func (docker *dockerConfig) useECRLogin(image string) bool {
+	if _, found := docker.getRegistryAuthEntry(image); found || !reECR.MatchString(image) {
+		return false
+	}
+	return docker.awsConfigExist()
+}
+
+// getECRTokenExpiry returns the expiry of the last ECR token obtained by tgf for the registry of the image
+func getECRTokenExpiry(image string) time.Time {
+	return getLastRefresh(ecrTokenPrefix + normalizeRegistry(getRegistryServer(image)))
+}
+
+func isECRTokenValid(expiry time.Time) bool {
+	return time.Until(expiry) > ecrTokenExpiryMargin
+}
+
 func (docker *dockerConfig) tryLoginToECR(image string) error {
 	matches, _ := reutils.MultiMatch(image, reECR)
 	account, accountOk := matches["account"]
@@ -547,16 +841,13 @@ func (docker *dockerConfig) tryLoginToECR(image string) error {
 	if err := dockerLoginCmd.Run(); err != nil {
 		return errors.Managed(err.Error())
 	}
+	if expiresAt := result.AuthorizationData[0].ExpiresAt; expiresAt != nil {
+		touchUntil(ecrTokenPrefix+normalizeRegistry(*result.AuthorizationData[0].ProxyEndpoint), *expiresAt)
+	}
 
 	return nil
 }
 
-func getDockerUpdateCmd(image string) *exec.Cmd {
-	dockerUpdateCmd := exec.Command("docker", "pull", image)
-	dockerUpdateCmd.Stdout, dockerUpdateCmd.Stderr = os.Stderr, os.Stderr
-	return dockerUpdateCmd
-}
-
 func getEnviron(noHome bool) (result []string) {
 	for _, env := range os.Environ() {
 		split := strings.Split(env, "=")
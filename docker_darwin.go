@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 )
 
 func getDockerMountArgs() []string {
@@ -14,3 +15,12 @@ func getDockerMountArgs() []string {
 func getDockerSocketMount() string {
 	return fmt.Sprintf("%[1]s.raw:%[1]s", dockerSocketFile)
 }
+
+// getSSHAgentSocket returns the path of the SSH agent socket to mount in the container
+// Docker Desktop exposes the host SSH agent through a special socket in its VM, the host socket cannot be mounted directly.
+func getSSHAgentSocket() (string, error) {
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		return "", fmt.Errorf("SSH_AUTH_SOCK is not defined")
+	}
+	return "/run/host-services/ssh-auth.sock", nil
+}
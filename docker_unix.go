@@ -23,3 +23,15 @@ func getDockerGroup() string {
 	s := must(os.Stat(dockerSocketFile)).(os.FileInfo)
 	return fmt.Sprintf("%v", s.Sys().(*syscall.Stat_t).Gid)
 }
+
+// getSSHAgentSocket returns the path of the SSH agent socket to mount in the container
+func getSSHAgentSocket() (string, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return "", fmt.Errorf("SSH_AUTH_SOCK is not defined")
+	}
+	if _, err := os.Stat(socket); err != nil {
+		return "", err
+	}
+	return socket, nil
+}
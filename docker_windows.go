@@ -15,3 +15,9 @@ func getDockerSocketMount() string {
 func getDockerGroup() string {
 	return "root"
 }
+
+// getSSHAgentSocket returns the path of the SSH agent socket to mount in the container
+func getSSHAgentSocket() (string, error) {
+	// The Windows SSH agent uses a named pipe that cannot be shared with Linux containers
+	return "", fmt.Errorf("SSH agent forwarding is not supported on Windows")
+}
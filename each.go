@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	eachFlag               = "each"
+	eachParallelismFlag    = "each-parallelism"
+	defaultEachParallelism = 4
+)
+
+// The runs in each folder must not prompt the user nor update tgf concurrently
+var eachChildArgs = []string{"--no-interactive", "--no-update"}
+
+// The exit code of the folders that are not processed because tgf has been interrupted
+const eachNotRun = -1
+
+// eachResult contains the outcome of the run in a folder
+type eachResult struct {
+	folder   string
+	exitCode int
+	duration time.Duration
+}
+
+// runEach runs tgf with the same arguments in each folder matching the patterns, each run uses the configuration of its folder
+func (app *TGFApplication) runEach() int {
+	if app.EachParallelism < 1 {
+		log.Errorf("Invalid --%s %d, it must be at least 1", eachParallelismFlag, app.EachParallelism)
+		return 1
+	}
+	folders, err := findEachFolders(app.Each)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	if len(folders) == 0 {
+		log.Errorf("No folder matches %s", strings.Join(app.Each, ", "))
+		return 1
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		log.Errorln("Executable path error:", err)
+		return 1
+	}
+
+	// The arguments of TGF_ARGS are passed explicitly since the variable is not transmitted to the runs
+	tgfArgs := removeEachArgs(append(getEnvArgs(), os.Args[1:]...))
+	args := append(append([]string{}, eachChildArgs...), tgfArgs...)
+	environ := getEachEnviron(os.Environ())
+	log.Infof("Running tgf %s in %d folders (%d at a time)", strings.Join(tgfArgs, " "), len(folders), app.EachParallelism)
+
+	// The signals are handled until all the runs are terminated, so the summary is always printed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	var processes eachProcesses
+	done := make(chan bool)
+	defer close(done)
+	go processes.handleSignals(signals, done)
+
+	var outputLock sync.Mutex
+	results := make([]eachResult, len(folders))
+	semaphore := make(chan bool, app.EachParallelism)
+	var wait sync.WaitGroup
+	for i, folder := range folders {
+		wait.Add(1)
+		semaphore <- true
+		go func(i int, folder string) {
+			defer func() { <-semaphore; wait.Done() }()
+			stdout := &prefixWriter{prefix: fmt.Sprintf("[%s] ", folder), out: os.Stdout, lock: &outputLock}
+			stderr := &prefixWriter{prefix: fmt.Sprintf("[%s] ", folder), out: os.Stderr, lock: &outputLock}
+			defer stdout.Flush()
+			defer stderr.Flush()
+
+			cmd := exec.Command(executable, args...)
+			cmd.Dir, cmd.Env, cmd.Stdout, cmd.Stderr = folder, environ, stdout, stderr
+			start := time.Now()
+			if !processes.start(cmd) {
+				results[i] = eachResult{folder, eachNotRun, 0}
+				return
+			}
+			results[i] = eachResult{folder, getExitCode(processes.wait(cmd)), time.Since(start)}
+		}(i, folder)
+	}
+	wait.Wait()
+
+	printEachSummary(os.Stdout, results)
+	exitCode := 0
+	for _, result := range results {
+		if result.exitCode > exitCode {
+			exitCode = result.exitCode
+		} else if result.exitCode == eachNotRun && exitCode == 0 {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// eachProcesses keeps track of the running processes to forward them the signals received by tgf
+type eachProcesses struct {
+	sync.Mutex
+	running     map[*exec.Cmd]bool
+	interrupted bool
+}
+
+// start starts the process unless tgf has been interrupted, the command must then be waited with wait
+func (processes *eachProcesses) start(cmd *exec.Cmd) bool {
+	processes.Lock()
+	defer processes.Unlock()
+	if processes.interrupted {
+		return false
+	}
+	if err := cmd.Start(); err != nil {
+		log.Errorln("Unable to run tgf:", err)
+		return false
+	}
+	if processes.running == nil {
+		processes.running = make(map[*exec.Cmd]bool)
+	}
+	processes.running[cmd] = true
+	return true
+}
+
+func (processes *eachProcesses) wait(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+	processes.Lock()
+	defer processes.Unlock()
+	delete(processes.running, cmd)
+	return err
+}
+
+// handleSignals stops processing new folders once tgf is interrupted. Like in runWithSignals, the interrupt signal (Ctrl-C) is already
+// received by the runs since they belong to the same process group, the other signals are forwarded to them.
+func (processes *eachProcesses) handleSignals(signals <-chan os.Signal, done <-chan bool) {
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-signals:
+			processes.Lock()
+			processes.interrupted = true
+			if sig != os.Interrupt {
+				for cmd := range processes.running {
+					log.Debugf("Forwarding %v to tgf in %s", sig, cmd.Dir)
+					if err := cmd.Process.Signal(sig); err != nil {
+						log.Debugf("Unable to forward %v to tgf in %s: %v", sig, cmd.Dir, err)
+					}
+				}
+			}
+			processes.Unlock()
+		}
+	}
+}
+
+// findEachFolders returns the sorted list of folders matching the patterns, the files are ignored
+func findEachFolders(patterns []string) (folders []string, err error) {
+	found := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s pattern %s: %v", eachFlag, pattern, err)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() && !found[match] {
+				found[match] = true
+				folders = append(folders, match)
+			}
+		}
+	}
+	sort.Strings(folders)
+	return
+}
+
+// removeEachArgs removes the --each and --each-parallelism arguments, the arguments following -- are passed to the command and kept as is
+func removeEachArgs(args []string) (result []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(result, args[i:]...)
+		}
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if strings.HasPrefix(arg, "--") && (name == eachFlag || name == eachParallelismFlag) {
+			if !hasValue {
+				// The value is the next argument
+				i++
+			}
+			continue
+		}
+		result = append(result, arg)
+	}
+	return
+}
+
+// getEachEnviron returns the environment of the runs in each folder, the variables that would start another --each are removed
+// as well as TGF_ARGS whose arguments are passed on the command line
+func getEachEnviron(environ []string) (result []string) {
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		switch name {
+		case "TGF_EACH", "TGF_EACH_PARALLELISM", envArgs:
+			continue
+		}
+		result = append(result, variable)
+	}
+	return
+}
+
+func getExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitError, isExitError := err.(*exec.ExitError); isExitError && exitError.ExitCode() > 0 {
+		return exitError.ExitCode()
+	}
+	log.Errorln("Unable to run tgf:", err)
+	return 1
+}
+
+func printEachSummary(out io.Writer, results []eachResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "FOLDER\tEXIT CODE\tDURATION")
+	for _, result := range results {
+		if result.exitCode == eachNotRun {
+			fmt.Fprintf(w, "%s\tnot run\t\n", result.folder)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%v\n", result.folder, result.exitCode, result.duration.Round(100*time.Millisecond))
+	}
+	w.Flush()
+}
+
+// prefixWriter writes each complete line with a prefix, the lines of concurrent writers sharing the lock are not interleaved
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	lock   *sync.Mutex
+	buffer []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buffer = append(w.buffer, p...)
+	if end := bytes.LastIndexByte(w.buffer, '\n'); end >= 0 {
+		w.write(w.buffer[:end+1])
+		w.buffer = w.buffer[end+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line even if it is not terminated by a new line
+func (w *prefixWriter) Flush() {
+	if len(w.buffer) > 0 {
+		w.write(append(w.buffer, '\n'))
+		w.buffer = nil
+	}
+}
+
+func (w *prefixWriter) write(lines []byte) {
+	var output bytes.Buffer
+	for _, line := range bytes.SplitAfter(lines, []byte("\n")) {
+		if len(line) > 0 {
+			output.WriteString(w.prefix)
+			output.Write(line)
+		}
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.out.Write(output.Bytes())
+}
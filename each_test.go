@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveEachArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"No each", []string{"--li", "plan"}, []string{"--li", "plan"}},
+		{"Each with value", []string{"--each", "accounts/*", "--li", "plan"}, []string{"--li", "plan"}},
+		{"Each with equal", []string{"--each=accounts/*", "--each=other/*", "plan"}, []string{"plan"}},
+		{"Parallelism", []string{"--each=a", "--each-parallelism", "8", "--", "plan"}, []string{"--", "plan"}},
+		{"After double dash", []string{"--each=a", "--", "plan", "--each", "x"}, []string{"--", "plan", "--each", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, removeEachArgs(tt.args))
+		})
+	}
+}
+
+func TestGetEachEnviron(t *testing.T) {
+	environ := []string{"HOME=/home/user", "TGF_EACH=a/*", "TGF_EACH_PARALLELISM=2", "TGF_ARGS=--li --each b/* -D"}
+	assert.Equal(t, []string{"HOME=/home/user"}, getEachEnviron(environ), "The arguments of TGF_ARGS are passed on the command line")
+}
+
+func TestFindEachFolders(t *testing.T) {
+	root := t.TempDir()
+	for _, folder := range []string{"b/us-east-1", "a/us-east-1", "a/eu-west-1"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, folder), 0755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "file"), nil, 0644))
+
+	folders, err := findEachFolders([]string{filepath.Join(root, "*", "us-east-1"), filepath.Join(root, "a", "*"), filepath.Join(root, "*")})
+	assert.NoError(t, err)
+	var relative []string
+	for _, folder := range folders {
+		relative = append(relative, filepath.ToSlash(must(filepath.Rel(root, folder)).(string)))
+	}
+	assert.Equal(t, []string{"a", "a/eu-west-1", "a/us-east-1", "b", "b/us-east-1"}, relative)
+
+	_, err = findEachFolders([]string{"["})
+	assert.Error(t, err)
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{prefix: "[a] ", out: &out, lock: &sync.Mutex{}}
+	w.Write([]byte("first line\nsecond "))
+	assert.Equal(t, "[a] first line\n", out.String())
+	w.Write([]byte("line\nthird\nlast"))
+	w.Flush()
+	assert.Equal(t, "[a] first line\n[a] second line\n[a] third\n[a] last\n", out.String())
+}
+
+func TestPrintEachSummary(t *testing.T) {
+	var out bytes.Buffer
+	printEachSummary(&out, []eachResult{{"accounts/dev", 0, 1234 * time.Millisecond}, {"accounts/production", 2, time.Minute}, {"accounts/qa", eachNotRun, 0}})
+	assert.Equal(t, ""+
+		"FOLDER                EXIT CODE   DURATION\n"+
+		"accounts/dev          0           1.2s\n"+
+		"accounts/production   2           1m0s\n"+
+		"accounts/qa           not run     \n", out.String())
+}
+
+func TestEachProcessesSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The signals cannot be forwarded on Windows")
+	}
+
+	var processes eachProcesses
+	signals, done := make(chan os.Signal, 1), make(chan bool)
+	defer close(done)
+	go processes.handleSignals(signals, done)
+
+	cmd := exec.Command("sleep", "60")
+	assert.True(t, processes.start(cmd))
+	signals <- syscall.SIGTERM
+	err := processes.wait(cmd)
+	assert.Error(t, err, "The terminate signal is forwarded to the runs")
+	assert.Empty(t, processes.running)
+	assert.False(t, processes.start(exec.Command("sleep", "60")), "No folder is processed once tgf is interrupted")
+}
+
+func TestGetExitCode(t *testing.T) {
+	assert.Equal(t, 0, getExitCode(nil))
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, 3, getExitCode(exec.Command("sh", "-c", "exit 3").Run()))
+	}
+	assert.Equal(t, 1, getExitCode(exec.Command("tgf-command-that-does-not-exist").Run()))
+}
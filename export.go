@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	exportDevcontainerFolder = ".devcontainer"
+	exportDevcontainerFile   = "devcontainer.json"
+	exportDockerfile         = "Dockerfile"
+	exportComposeFile        = "compose.yaml"
+	exportServiceName        = "tgf"
+)
+
+// The host variables that are referenced by the exported definitions in addition to the secrets
+var exportHostVariables = []string{"AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION"}
+
+// devcontainer contains the subset of the devcontainer.json properties generated by tgf
+type devcontainer struct {
+	Name            string             `json:"name"`
+	Image           string             `json:"image,omitempty"`
+	Build           *devcontainerBuild `json:"build,omitempty"`
+	WorkspaceMount  string             `json:"workspaceMount"`
+	WorkspaceFolder string             `json:"workspaceFolder"`
+	Mounts          []string           `json:"mounts,omitempty"`
+	ContainerEnv    map[string]string  `json:"containerEnv,omitempty"`
+	RunArgs         []string           `json:"runArgs,omitempty"`
+}
+
+type devcontainerBuild struct {
+	Dockerfile string `json:"dockerfile"`
+	Context    string `json:"context"`
+}
+
+// composeProject contains the subset of the compose specification generated by tgf
+type composeProject struct {
+	Services map[string]composeService `yaml:"services"`
+	Volumes  map[string]composeVolume  `yaml:"volumes,omitempty"`
+}
+
+type composeService struct {
+	Image       string         `yaml:"image,omitempty"`
+	Build       *composeBuild  `yaml:"build,omitempty"`
+	Command     []string       `yaml:"command,omitempty"`
+	WorkingDir  string         `yaml:"working_dir"`
+	User        string         `yaml:"user,omitempty"`
+	GroupAdd    []string       `yaml:"group_add,omitempty"`
+	Environment []string       `yaml:"environment,omitempty"`
+	Volumes     []composeMount `yaml:"volumes,omitempty"`
+	CPUs        string         `yaml:"cpus,omitempty"`
+	MemLimit    string         `yaml:"mem_limit,omitempty"`
+	NetworkMode string         `yaml:"network_mode,omitempty"`
+	Platform    string         `yaml:"platform,omitempty"`
+	DNS         []string       `yaml:"dns,omitempty"`
+	ExtraHosts  []string       `yaml:"extra_hosts,omitempty"`
+	Ports       []string       `yaml:"ports,omitempty"`
+	StdinOpen   bool           `yaml:"stdin_open"`
+	TTY         bool           `yaml:"tty"`
+}
+
+type composeBuild struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+type composeMount struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source,omitempty"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only,omitempty"`
+}
+
+type composeVolume struct {
+	Name string `yaml:"name"`
+}
+
+// exportSyntax defines how the host paths and variables are written in an exported definition
+type exportSyntax struct {
+	workspace string // Reference to the folder where tgf is invoked
+	variable  func(name string) string
+}
+
+var (
+	devcontainerSyntax = exportSyntax{"${localWorkspaceFolder}", func(name string) string { return "${localEnv:" + name + "}" }}
+	composeSyntax      = exportSyntax{".", func(name string) string { return "${" + name + "}" }}
+)
+
+// path returns the host path as it must be written in the exported definition
+// Paths within the sources are relative to the current folder and paths within the home folder are relative to the home variable
+func (syntax exportSyntax) path(hostPath, cwd, root string) string {
+	hostPath = filepath.ToSlash(hostPath)
+	relativeTo := func(base, prefix string) string {
+		relative := filepath.ToSlash(must(filepath.Rel(base, hostPath)).(string))
+		if relative == "." {
+			return prefix
+		}
+		return prefix + "/" + relative
+	}
+	if isSubPath(root, hostPath) {
+		return relativeTo(cwd, syntax.workspace)
+	}
+	if home, err := os.UserHomeDir(); err == nil && isSubPath(filepath.ToSlash(home), hostPath) {
+		return relativeTo(filepath.ToSlash(home), syntax.variable(getHomeVariable()))
+	}
+	return hostPath
+}
+
+// source returns the source of the mount as it must be written in the exported definition
+func (syntax exportSyntax) source(m TGFConfigMount, cwd, root string) string {
+	switch {
+	case m.mountType() != mountTypeBind:
+		return m.Source
+	case m.Target == sshAgentSocketTarget:
+		// The agent socket changes on each session
+		return syntax.variable("SSH_AUTH_SOCK")
+	default:
+		return syntax.path(m.HostPath(), cwd, root)
+	}
+}
+
+func getHomeVariable() string {
+	if runtime.GOOS == "windows" {
+		return "USERPROFILE"
+	}
+	return "HOME"
+}
+
+// export writes a devcontainer definition and a compose file running the same image, mounts and environment as the docker invocation
+func (docker *dockerConfig) export(run *dockerRun) int {
+	app, config := docker.tgf, docker.TGFConfig
+	cwd := filepath.ToSlash(must(filepath.EvalSymlinks(must(os.Getwd()).(string))).(string))
+	devFolder := filepath.Join(cwd, exportDevcontainerFolder)
+	files := []string{filepath.Join(devFolder, exportDevcontainerFile), filepath.Join(cwd, exportComposeFile)}
+
+	dockerfile, context, built := docker.getExportBuild()
+	if !built {
+		log.Warningf("The image build instructions cannot be combined in a single Dockerfile, the image %s built by tgf is used instead", run.Image)
+		dockerfile = ""
+	} else if dockerfile != "" {
+		files = append(files, filepath.Join(devFolder, exportDockerfile))
+		if context == "" {
+			context = devFolder
+		}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			log.Errorf("%s already exists, remove it to generate a new one", file)
+			return 1
+		}
+	}
+	for _, note := range run.Notes {
+		log.Warning(note)
+	}
+
+	root := filepath.ToSlash(run.sources.Source)
+	environment, references := run.exportEnvironment()
+
+	dev := devcontainer{
+		Name:            fmt.Sprintf("tgf %s", filepath.Base(cwd)),
+		WorkspaceMount:  run.sources.formatArg(devcontainerSyntax.source(run.sources, cwd, root)),
+		WorkspaceFolder: run.WorkDir,
+		ContainerEnv:    environment,
+	}
+	service := composeService{
+		Command:     run.Command,
+		WorkingDir:  run.WorkDir,
+		CPUs:        config.CPUs,
+		MemLimit:    config.Memory,
+		NetworkMode: config.Network,
+		Platform:    config.Platform,
+		DNS:         config.DNS,
+		ExtraHosts:  config.AddHosts,
+		Ports:       config.Publish,
+		StdinOpen:   true,
+		TTY:         true,
+	}
+	if dockerfile == "" {
+		dev.Image, service.Image = run.Image, run.Image
+	} else {
+		dev.Build = &devcontainerBuild{
+			Dockerfile: exportDockerfile,
+			Context:    filepath.ToSlash(must(filepath.Rel(devFolder, context)).(string)),
+		}
+		service.Build = &composeBuild{
+			Context:    composeSyntax.path(context, cwd, context),
+			Dockerfile: filepath.ToSlash(must(filepath.Rel(context, filepath.Join(devFolder, exportDockerfile))).(string)),
+		}
+	}
+
+	for name, value := range environment {
+		service.Environment = append(service.Environment, fmt.Sprintf("%s=%s", name, strings.ReplaceAll(value, "$", "$$")))
+	}
+	for _, name := range references {
+		dev.ContainerEnv[name] = devcontainerSyntax.variable(name)
+		service.Environment = append(service.Environment, name)
+	}
+	sort.Strings(service.Environment)
+
+	project := composeProject{Services: map[string]composeService{}, Volumes: map[string]composeVolume{}}
+	mounts := []TGFConfigMount{run.sources}
+	for _, m := range run.Mounts {
+		if m.Target != run.sources.Target {
+			mounts = append(mounts, m)
+			dev.Mounts = append(dev.Mounts, m.formatArg(devcontainerSyntax.source(m, cwd, root)))
+		}
+	}
+	for _, m := range mounts {
+		service.Volumes = append(service.Volumes, composeMount{m.mountType(), composeSyntax.source(m, cwd, root), m.Target, m.ReadOnly})
+		if m.mountType() == mountTypeVolume && m.Source != "" {
+			// The volumes are shared with tgf, so they must not be prefixed by the compose project name
+			project.Volumes[m.Source] = composeVolume{m.Source}
+		}
+	}
+
+	if app.WithCurrentUser && runtime.GOOS != "windows" {
+		currentUser := must(user.Current()).(*user.User)
+		service.User = fmt.Sprintf("%s:%s", currentUser.Uid, currentUser.Gid)
+		dev.RunArgs = append(dev.RunArgs, "--user="+service.User)
+	}
+	if app.WithDockerMount {
+		dockerMountArgs := getDockerMountArgs()
+		dev.RunArgs = append(dev.RunArgs, dockerMountArgs...)
+		socket := getDockerSocketMount()
+		separator := strings.LastIndex(socket, ":")
+		service.Volumes = append(service.Volumes, composeMount{Type: mountTypeBind, Source: socket[:separator], Target: socket[separator+1:]})
+		service.GroupAdd = []string{dockerMountArgs[len(dockerMountArgs)-1]}
+	}
+	dev.RunArgs = append(dev.RunArgs, config.getResourceArgs()...)
+	dev.RunArgs = append(dev.RunArgs, config.getPlatformArgs()...)
+	dev.RunArgs = append(dev.RunArgs, config.DockerOptions...)
+	for _, do := range app.DockerOptions {
+		dev.RunArgs = append(dev.RunArgs, strings.Split(do, " ")...)
+	}
+	if len(config.DockerOptions)+len(app.DockerOptions) > 0 {
+		log.Warningf("The docker options are not converted in %s", exportComposeFile)
+	}
+	project.Services[exportServiceName] = service
+
+	must(os.MkdirAll(devFolder, 0755))
+	must(os.WriteFile(files[0], append(must(json.MarshalIndent(dev, "", "  ")).([]byte), '\n'), 0644))
+	must(os.WriteFile(files[1], must(yaml.Marshal(project)).([]byte), 0644))
+	if len(files) > 2 {
+		must(os.WriteFile(files[2], []byte(dockerfile), 0644))
+	}
+	for _, file := range files {
+		log.Info("Generated ", file)
+	}
+	return 0
+}
+
+// exportEnvironment returns the variables of the exported definitions, the secrets and the host variables are returned as references
+// since their values must not be written in the generated files
+func (run *dockerRun) exportEnvironment() (environment map[string]string, references []string) {
+	environment = make(map[string]string)
+	referenced := make(map[string]bool)
+	for name, value := range run.exported {
+		switch {
+		case name == "TGF_ARGS":
+			// The arguments of the export itself are meaningless in the exported definitions
+		case run.isSecret != nil && run.isSecret(name):
+			referenced[name] = true
+		default:
+			environment[name] = value
+		}
+	}
+	for _, name := range append(run.Environment, exportHostVariables...) {
+		if _, isSet := os.LookupEnv(name); !isSet {
+			continue
+		}
+		if _, exported := environment[name]; exported {
+			continue
+		}
+		if run.isSecret != nil && run.isSecret(name) || listContainsElement(exportHostVariables, name) {
+			referenced[name] = true
+		}
+	}
+	for name := range referenced {
+		references = append(references, name)
+	}
+	sort.Strings(references)
+	return
+}
+
+// getExportBuild returns the content of a Dockerfile combining all image build instructions and the folder used as build context
+// built is false if the instructions cannot be combined in a single Dockerfile
+func (docker *dockerConfig) getExportBuild() (dockerfile, context string, built bool) {
+	if !docker.tgf.DockerBuild || len(docker.imageBuildConfigs) == 0 {
+		return "", "", true
+	}
+	base := docker.GetImageName()
+	if !strings.Contains(base, ":") {
+		base += ":latest"
+	}
+	lines := []string{fmt.Sprintf("# Generated by tgf %s", version), "FROM " + base}
+	for _, ib := range docker.imageBuildConfigs {
+		if ib.Instructions == "" {
+			// The Dockerfile is provided by the build folder
+			return "", "", false
+		}
+		if ib.Folder != "" {
+			if context != "" && context != ib.Dir() {
+				return "", "", false
+			}
+			context = ib.Dir()
+		}
+		lines = append(lines, "", "# "+ib.source, strings.TrimSpace(ib.Instructions))
+	}
+	return strings.Join(lines, "\n") + "\n", context, true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportSyntaxPath(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	home = filepath.ToSlash(home)
+
+	tests := []struct {
+		name     string
+		hostPath string
+		syntax   exportSyntax
+		want     string
+	}{
+		{"Current folder", "/sources/project/live", devcontainerSyntax, "${localWorkspaceFolder}"},
+		{"Parent folder", "/sources/project", devcontainerSyntax, "${localWorkspaceFolder}/.."},
+		{"Sibling folder", "/sources/project/modules", composeSyntax, "./../modules"},
+		{"Home folder", home + "/.gitconfig", devcontainerSyntax, "${localEnv:HOME}/.gitconfig"},
+		{"Home folder in compose", home + "/.gitconfig", composeSyntax, "${HOME}/.gitconfig"},
+		{"Other folder", "/opt/modules", composeSyntax, "/opt/modules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.syntax.path(tt.hostPath, "/sources/project/live", "/sources/project"))
+		})
+	}
+}
+
+func TestExportEnvironment(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "dev")
+	t.Setenv("GITHUB_TOKEN", "ghp_123")
+	t.Setenv("UNRELATED", "value")
+
+	config := &TGFConfig{SecretVariables: []string{"GITHUB_TOKEN"}}
+	run := &dockerRun{
+		Environment: []string{"AWS_PROFILE", "GITHUB_TOKEN", "UNRELATED", "AWS_SESSION_TOKEN"},
+		exported:    map[string]string{"TGF_ARGS": "tgf --export", "TGF_COMMAND": "terragrunt", "AWS_SECRET_ACCESS_KEY": "secret"},
+		isSecret:    config.isSecretVariable,
+	}
+	environment, references := run.exportEnvironment()
+	assert.Equal(t, map[string]string{"TGF_COMMAND": "terragrunt"}, environment)
+	assert.Equal(t, []string{"AWS_PROFILE", "AWS_SECRET_ACCESS_KEY", "GITHUB_TOKEN"}, references)
+}
+
+func TestGetExportBuild(t *testing.T) {
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	config := &TGFConfig{
+		Image: "coveo/tgf",
+		tgf:   &TGFApplication{DockerBuild: true},
+		imageBuildConfigs: []TGFConfigBuild{
+			{Instructions: "RUN apk add jq", source: "/project/.tgf.config"},
+			{Instructions: "COPY . /opt/tools\n", Folder: tempDir, source: "/project/live/.tgf.config"},
+		},
+	}
+	docker := dockerConfig{config}
+
+	dockerfile, context, built := docker.getExportBuild()
+	assert.True(t, built)
+	assert.Equal(t, tempDir, context)
+	assert.Equal(t, "# Generated by tgf "+version+"\nFROM coveo/tgf:latest\n\n# /project/.tgf.config\nRUN apk add jq\n\n# /project/live/.tgf.config\nCOPY . /opt/tools\n", dockerfile)
+
+	config.imageBuildConfigs = append(config.imageBuildConfigs, TGFConfigBuild{Folder: t.TempDir(), source: "/project/live/stack/.tgf.config"})
+	_, _, built = docker.getExportBuild()
+	assert.False(t, built, "A build folder without instructions cannot be combined")
+
+	config.tgf.DockerBuild = false
+	dockerfile, _, built = docker.getExportBuild()
+	assert.True(t, built)
+	assert.Empty(t, dockerfile)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	forwardFolder         = "/run/tgf"
+	sshAgentSocketTarget  = forwardFolder + "/ssh-agent.sock"
+	gitCredentialsTarget  = forwardFolder + "/git-credentials"
+	gitCredentialsPattern = "tgf-git-credentials"
+)
+
+// getForwardMounts returns the mounts required to forward the SSH agent, the git configuration, the known hosts
+// and the git credentials to the container. The git credentials are given to the owner (uid[:gid]) if the container runs as another
+// user (see getSecretMounts). The returned function must be called once the container is terminated.
+func (docker *dockerConfig) getForwardMounts(containerHome, owner string) (mounts []TGFConfigMount, cleanup func()) {
+	app, config := docker.tgf, docker.TGFConfig
+	cleanup = func() {}
+
+	if config.ForwardSSHAgent {
+		if socket, err := getSSHAgentSocket(); err != nil {
+			log.Warning("Unable to forward the SSH agent: ", err)
+		} else {
+			mounts = append(mounts, TGFConfigMount{Source: socket, Target: sshAgentSocketTarget})
+			config.Environment["SSH_AUTH_SOCK"] = sshAgentSocketTarget
+		}
+	}
+
+	if (config.ForwardGitConfig || config.ForwardKnownHosts) && !app.MountHomeDir {
+		// If the home directory is mounted, the files are already available in the container
+		if containerHome == "" {
+			log.Warning("Unable to forward the git configuration and the known hosts since there is no home folder in the container")
+		} else {
+			if config.ForwardGitConfig {
+				mounts = append(mounts, TGFConfigMount{Source: "~/.gitconfig", Target: path.Join(containerHome, ".gitconfig"), ReadOnly: true, Optional: true})
+			}
+			if config.ForwardKnownHosts {
+				mounts = append(mounts, TGFConfigMount{Source: "~/.ssh/known_hosts", Target: path.Join(containerHome, ".ssh", "known_hosts"), ReadOnly: true, Optional: true})
+			}
+		}
+	}
+
+	if len(config.GitCredentialHosts) > 0 {
+		if file := writeGitCredentials(config.GitCredentialHosts); file != "" && owner != "" && !shareSecrets(owner, file) {
+			log.Warningf("The git credentials cannot be given to the user %s of the image, they are not forwarded (use --with-current-user to forward them)", owner)
+			os.Remove(file)
+		} else if file != "" {
+			cleanup = func() { os.Remove(file) }
+			mounts = append(mounts, TGFConfigMount{Source: file, Target: gitCredentialsTarget, ReadOnly: true})
+			for key, value := range gitCredentialsEnvironment(gitCredentialsTarget) {
+				config.Environment[key] = value
+			}
+		}
+	}
+
+	return
+}
+
+// writeGitCredentials retrieves the credentials of the hosts from the host git credential helpers and stores
+// them in a temporary file using the git-credential-store format. Like the secrets, the file is kept in memory when possible
+// and it is removed by the cleanup of the run.
+func writeGitCredentials(hosts []string) string {
+	var lines []string
+	for _, host := range hosts {
+		fill := exec.Command("git", "credential", "fill")
+		fill.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+		fill.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		output, err := fill.Output()
+		if err != nil {
+			log.Warningf("Unable to get the git credentials for %s: %v", host, err)
+			continue
+		}
+		if line := gitCredentialLine(string(output)); line != "" {
+			lines = append(lines, line)
+		} else {
+			log.Warningf("No git credentials found for %s", host)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	file := must(os.CreateTemp(getSecretsBaseFolder(), gitCredentialsPattern)).(*os.File)
+	must(fmt.Fprintln(file, strings.Join(lines, "\n")))
+	must(file.Close())
+	return filepath.ToSlash(file.Name())
+}
+
+// gitCredentialLine converts the output of git credential fill into a git-credential-store entry
+func gitCredentialLine(output string) string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if key, value, found := strings.Cut(scanner.Text(), "="); found {
+			values[key] = value
+		}
+	}
+	if values["host"] == "" || values["password"] == "" {
+		return ""
+	}
+	protocol := values["protocol"]
+	if protocol == "" {
+		protocol = "https"
+	}
+	credentials := url.URL{Scheme: protocol, User: url.UserPassword(values["username"], values["password"]), Host: values["host"]}
+	return credentials.String()
+}
+
+// gitCredentialsEnvironment returns the variables configuring git in the container to read the credentials from the file
+// without altering the git configuration files (the helper only answers to get requests since the file is read-only)
+func gitCredentialsEnvironment(file string) map[string]string {
+	return map[string]string{
+		"GIT_CONFIG_COUNT":   "1",
+		"GIT_CONFIG_KEY_0":   "credential.helper",
+		"GIT_CONFIG_VALUE_0": fmt.Sprintf(`!f() { test "$1" = get && git credential-store --file=%s get; }; f`, file),
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// The registry is queried for the manifest digest only, so the check must be fast
+const remoteDigestTimeout = 30 * time.Second
+
+// getRemoteDigest returns the digest of the image manifest in the registry without pulling the image
+func (docker *dockerConfig) getRemoteDigest(image string) (string, error) {
+	docker.renewECRLogin(image)
+	auth, err := docker.getRegistryAuth(image)
+	if err != nil {
+		log.Warningf("Unable to get the registry credentials of %s: %v", image, err)
+	}
+
+	cli, ctx := getDockerClient()
+	ctx, cancel := context.WithTimeout(ctx, remoteDigestTimeout)
+	defer cancel()
+	distribution, err := cli.DistributionInspect(ctx, image, auth)
+	if err != nil {
+		return "", err
+	}
+	return distribution.Descriptor.Digest.String(), nil
+}
+
+// getLocalDigests returns the digests of the local image in the repository of the image
+func getLocalDigests(image string) []string {
+	if summary := getImageSummary(image); summary != nil {
+		return filterRepoDigests(image, summary.RepoDigests)
+	}
+	return nil
+}
+
+// filterRepoDigests returns the digests of the repo digests (repository@digest) that belong to the repository of the image
+func filterRepoDigests(image string, repoDigests []string) (digests []string) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil
+	}
+	for _, repoDigest := range repoDigests {
+		canonical, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil || canonical.Name() != named.Name() {
+			continue
+		}
+		if digested, ok := canonical.(reference.Digested); ok {
+			digests = append(digests, digested.Digest().String())
+		}
+	}
+	return
+}
+
+// isImageUpToDate checks if the image available locally has the same digest as the image in the registry, the image must be pulled if
+// the registry cannot be queried
+func (docker *dockerConfig) isImageUpToDate(image string, localDigests []string) bool {
+	if len(localDigests) == 0 {
+		// The image has been built or loaded locally, there is nothing to compare with
+		return false
+	}
+	remoteDigest, err := docker.getRemoteDigest(image)
+	if err != nil {
+		log.Debugf("Unable to get the digest of %s in the registry: %v", image, err)
+		return false
+	}
+	if !listContainsElement(localDigests, remoteDigest) {
+		log.Debugf("The digest of %s in the registry (%s) differs from the local one (%s)", image, remoteDigest, strings.Join(localDigests, ", "))
+		return false
+	}
+	log.Debugf("The image %s is up to date (%s)", image, shortDigest(remoteDigest))
+	return true
+}
+
+// reportImageUpdate informs the user when the pull has changed the local image
+func reportImageUpdate(image string, before, after []string) {
+	if len(before) == 0 || len(after) == 0 {
+		return
+	}
+	for _, digest := range after {
+		if listContainsElement(before, digest) {
+			return
+		}
+	}
+	log.Infof("Image %s updated from %s to %s", image, shortDigest(before[0]), shortDigest(after[0]))
+}
+
+// shortDigest returns the algorithm and the first 12 characters of the digest like docker does for the image IDs
+func shortDigest(digest string) string {
+	algorithm, hash, found := strings.Cut(digest, ":")
+	if !found || len(hash) <= 12 {
+		return digest
+	}
+	return algorithm + ":" + hash[:12]
+}
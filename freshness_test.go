@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterRepoDigests(t *testing.T) {
+	const (
+		digest1 = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		digest2 = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+	tests := []struct {
+		name        string
+		image       string
+		repoDigests []string
+		want        []string
+	}{
+		{"No digest", "coveo/tgf:latest", nil, nil},
+		{"Docker Hub", "coveo/tgf:latest", []string{"coveo/tgf@" + digest1}, []string{digest1}},
+		{"Normalized name", "docker.io/coveo/tgf:1.2", []string{"coveo/tgf@" + digest1}, []string{digest1}},
+		{"Other repository", "coveo/tgf", []string{"coveo/other@" + digest1, "coveo/tgf@" + digest2}, []string{digest2}},
+		{"Private registry", "123456789012.dkr.ecr.us-east-1.amazonaws.com/tgf:1", []string{"coveo/tgf@" + digest1, "123456789012.dkr.ecr.us-east-1.amazonaws.com/tgf@" + digest2}, []string{digest2}},
+		{"Invalid entry", "coveo/tgf", []string{"<none>@<none>"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, filterRepoDigests(tt.image, tt.repoDigests))
+		})
+	}
+}
+
+func TestShortDigest(t *testing.T) {
+	assert.Equal(t, "sha256:0123456789ab", shortDigest("sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"))
+	assert.Equal(t, "sha256:0123", shortDigest("sha256:0123"))
+	assert.Equal(t, "invalid", shortDigest("invalid"))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// At least one of these variables is defined by the usual CI systems
+var ciVariables = []string{
+	"CI", "BUILD_NUMBER", "JENKINS_URL", "GITHUB_ACTIONS", "GITLAB_CI", "TF_BUILD", "BUILDKITE", "CIRCLECI", "TRAVIS",
+	"CODEBUILD_BUILD_ID", "TEAMCITY_VERSION", "BITBUCKET_BUILD_NUMBER",
+}
+
+// getInteractiveArgs returns the docker arguments attaching the input (-i) and allocating a terminal (-t)
+// An explicit --[no-]interactive has precedence over the detection from the terminal and the CI environment
+func (app *TGFApplication) getInteractiveArgs() []string {
+	if app.DockerInteractiveSet {
+		if app.DockerInteractive {
+			return []string{"-it"}
+		}
+		return nil
+	}
+	stdinTerminal := term.IsTerminal(int(os.Stdin.Fd()))
+	args := detectInteractiveArgs(stdinTerminal, term.IsTerminal(int(os.Stdout.Fd())), !stdinTerminal && isInputRedirected(), isCI())
+	log.Debugf("Interactive arguments detected from the environment: %v", args)
+	return args
+}
+
+// detectInteractiveArgs returns -it when tgf is used from a terminal and -i when the input comes from a pipe or a file
+// A terminal is never allocated in CI, nor when the output is redirected
+func detectInteractiveArgs(stdinTerminal, stdoutTerminal, inputRedirected, ci bool) []string {
+	switch {
+	case stdinTerminal && stdoutTerminal && !ci:
+		return []string{"-it"}
+	case inputRedirected || stdinTerminal && !ci:
+		return []string{"-i"}
+	}
+	return nil
+}
+
+// isInputRedirected returns true if the input is a pipe or a file
+func isInputRedirected() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && (info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular())
+}
+
+func isCI() bool {
+	for _, name := range ciVariables {
+		if value := os.Getenv(name); value != "" && value != "false" && value != "0" {
+			return true
+		}
+	}
+	return false
+}
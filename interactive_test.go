@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectInteractiveArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		stdinTerminal   bool
+		stdoutTerminal  bool
+		inputRedirected bool
+		ci              bool
+		want            []string
+	}{
+		{"Terminal", true, true, false, false, []string{"-it"}},
+		{"Output piped", true, false, false, false, []string{"-i"}},
+		{"Input piped", false, true, true, false, []string{"-i"}},
+		{"No input", false, false, false, false, nil},
+		{"CI with a terminal", true, true, false, true, nil},
+		{"CI with input piped", false, false, true, true, []string{"-i"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectInteractiveArgs(tt.stdinTerminal, tt.stdoutTerminal, tt.inputRedirected, tt.ci))
+		})
+	}
+}
+
+func TestGetInteractiveArgsExplicit(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"-it"}, (&TGFApplication{DockerInteractive: true, DockerInteractiveSet: true}).getInteractiveArgs())
+	assert.Nil(t, (&TGFApplication{DockerInteractiveSet: true}).getInteractiveArgs())
+}
+
+func TestIsCI(t *testing.T) {
+	for _, name := range ciVariables {
+		t.Setenv(name, "")
+	}
+	assert.False(t, isCI())
+	t.Setenv("CI", "false")
+	assert.False(t, isCI())
+	t.Setenv("GITHUB_ACTIONS", "true")
+	assert.True(t, isCI())
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Folder of ~/.tgf containing the lock files
+const locksFolder = "locks"
+
+// acquireLock waits until the process holds an exclusive lock on the named resource (shared by all tgf processes of the user)
+// waited is true if the lock was held by another process. The locks are advisory, tgf runs without lock if it cannot be created.
+func acquireLock(name string) (release func(), waited bool) {
+	filename, err := getTouchFilename(name)
+	if err != nil {
+		log.Debugf("Unable to lock %s: %v", name, err)
+		return func() {}, false
+	}
+	hash := sha1.Sum([]byte(name))
+	return acquireFileLock(filepath.Join(filepath.Dir(filename), locksFolder, base64.RawURLEncoding.EncodeToString(hash[:])+".lock"), name)
+}
+
+// acquireFileLock waits until the process holds an exclusive lock on the file, the PID of the owner is written in the file
+func acquireFileLock(filename, name string) (release func(), waited bool) {
+	release = func() {}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		log.Debugf("Unable to lock %s: %v", name, err)
+		return
+	}
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		log.Debugf("Unable to lock %s: %v", name, err)
+		return
+	}
+
+	if err := lockFile(file, false); err != nil {
+		owner := "unknown"
+		if content, err := os.ReadFile(filename); err == nil && strings.TrimSpace(string(content)) != "" {
+			owner = strings.TrimSpace(string(content))
+		}
+		log.Warningf("Waiting for the lock on %s held by PID %s", name, owner)
+		waited = true
+		if err := lockFile(file, true); err != nil {
+			log.Debugf("Unable to lock %s: %v", name, err)
+			file.Close()
+			return
+		}
+	}
+	log.Debugf("Acquired the lock on %s", name)
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+
+	release = func() {
+		file.Truncate(0)
+		if err := unlockFile(file); err != nil {
+			log.Debugf("Unable to release the lock on %s: %v", name, err)
+		}
+		file.Close()
+	}
+	return
+}
+
+func getImageLockName(image string) string   { return fmt.Sprintf("image %s", image) }
+func getFolderLockName(folder string) string { return fmt.Sprintf("folder %s", folder) }
+func getVolumeLockName(volume string) string { return fmt.Sprintf("volume %s", volume) }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireFileLock(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), locksFolder, "test.lock")
+	release, waited := acquireFileLock(filename, "test")
+	assert.False(t, waited)
+	content, _ := os.ReadFile(filename)
+	assert.Equal(t, strconv.Itoa(os.Getpid()), string(content))
+
+	acquired := make(chan bool)
+	go func() {
+		releaseOther, waitedOther := acquireFileLock(filename, "test")
+		defer releaseOther()
+		acquired <- waitedOther
+	}()
+
+	select {
+	case <-acquired:
+		assert.Fail(t, "The lock should be held until it is released")
+	case <-time.After(200 * time.Millisecond):
+	}
+	release()
+
+	select {
+	case waitedOther := <-acquired:
+		assert.True(t, waitedOther)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "The lock has not been acquired after its release")
+	}
+}
+
+func TestAcquireFileLockInvalidFolder(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0644))
+	release, waited := acquireFileLock(filepath.Join(file, "test.lock"), "test")
+	assert.False(t, waited)
+	release()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	mountTypeBind   = "bind"
+	mountTypeVolume = "volume"
+)
+
+// TGFConfigMount contains an entry specifying a folder, a file or a volume that should be mounted in the docker container
+type TGFConfigMount struct {
+	Source   string `yaml:"source,omitempty" json:"source,omitempty" hcl:"source,omitempty"`
+	Target   string `yaml:"target,omitempty" json:"target,omitempty" hcl:"target,omitempty"`
+	Type     string `yaml:"type,omitempty" json:"type,omitempty" hcl:"type,omitempty"`
+	ReadOnly bool   `yaml:"read-only,omitempty" json:"read-only,omitempty" hcl:"read-only,omitempty"`
+	Optional bool   `yaml:"optional,omitempty" json:"optional,omitempty" hcl:"optional,omitempty"`
+	source   string
+}
+
+var reVolumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func (m TGFConfigMount) mountType() string {
+	if m.Type == "" {
+		return mountTypeBind
+	}
+	return strings.ToLower(m.Type)
+}
+
+// HostPath returns the bind mount source with ~ and environment variables expanded
+// Relative paths are resolved from the folder of the configuration file that declares the mount
+func (m TGFConfigMount) HostPath() string {
+	base := must(os.Getwd()).(string)
+	if filepath.IsAbs(m.source) {
+		base = filepath.Dir(m.source)
+	}
+	return expandPath(m.Source, base)
+}
+
+// expandPath returns the absolute path with ~ and environment variables expanded, relative paths are resolved from base
+func expandPath(source, base string) string {
+	source = os.ExpandEnv(source)
+	if source == "~" || strings.HasPrefix(source, "~/") || strings.HasPrefix(source, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			source = filepath.Join(home, source[1:])
+		}
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(base, source)
+	}
+	return filepath.Clean(source)
+}
+
+func (m TGFConfigMount) validate() error {
+	if m.Target == "" || !path.IsAbs(m.Target) {
+		return fmt.Errorf("invalid mount target '%s', it must be an absolute path in the container", m.Target)
+	}
+	switch m.mountType() {
+	case mountTypeBind:
+		if m.Source == "" {
+			return fmt.Errorf("the source of the mount on %s is required", m.Target)
+		}
+		if _, err := os.Stat(m.HostPath()); err != nil && !m.Optional {
+			return fmt.Errorf("unable to mount %s on %s: %v", m.Source, m.Target, err)
+		}
+	case mountTypeVolume:
+		if m.Source != "" && !reVolumeName.MatchString(m.Source) {
+			return fmt.Errorf("invalid volume name '%s' for the mount on %s", m.Source, m.Target)
+		}
+	default:
+		return fmt.Errorf("invalid mount type '%s' for %s, it must be %s or %s", m.Type, m.Target, mountTypeBind, mountTypeVolume)
+	}
+	return nil
+}
+
+// Arg returns the value of the --mount docker argument, fields are quoted when they contain a comma or a quote
+func (m TGFConfigMount) Arg() string {
+	source := m.Source
+	if m.mountType() == mountTypeBind {
+		source = convertDrive(filepath.ToSlash(m.HostPath()))
+	}
+	return m.formatArg(source)
+}
+
+// formatArg returns the value of the --mount argument using the supplied source as is
+func (m TGFConfigMount) formatArg(source string) string {
+	fields := []string{"type=" + m.mountType()}
+	if source != "" {
+		fields = append(fields, "source="+source)
+	}
+	fields = append(fields, "target="+m.Target)
+	if m.ReadOnly {
+		fields = append(fields, "readonly")
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	must(writer.Write(fields))
+	writer.Flush()
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// getMountArgs returns the docker arguments required to add the mounts, a mount replaces any previous mount having the same target
+// Missing optional sources are ignored
+func getMountArgs(mounts ...TGFConfigMount) (args []string) {
+	for _, m := range resolveMounts(mounts...) {
+		args = append(args, "--mount", m.Arg())
+	}
+	return
+}
+
+// resolveMounts returns the mounts that are actually added, a mount replaces any previous mount having the same target and
+// missing optional sources are ignored
+func resolveMounts(mounts ...TGFConfigMount) (result []TGFConfigMount) {
+	for _, m := range mergeMounts(nil, "", mounts...) {
+		if m.mountType() == mountTypeBind && m.Optional {
+			if _, err := os.Stat(m.HostPath()); err != nil {
+				log.Debugf("Skipping mount of %s on %s: %v", m.Source, m.Target, err)
+				continue
+			}
+		}
+		result = append(result, m)
+	}
+	return
+}
+
+// getHomeMounts returns the mounts of the home-mounts entries (relative to the user home folder) into the container home folder
+// The entries are mounted in read-only mode unless they are suffixed by :rw
+func (config *TGFConfig) getHomeMounts(containerHome string) (mounts []TGFConfigMount) {
+	if len(config.HomeMounts) == 0 {
+		return
+	}
+	if config.tgf.MountHomeDir {
+		log.Debug("The home mounts are ignored since the whole home folder is mounted")
+		return
+	}
+	if containerHome == "" {
+		log.Warning("Unable to mount the home-mounts entries since there is no home folder in the container")
+		return
+	}
+	for _, entry := range config.HomeMounts {
+		name, readWrite := parseHomeMount(entry)
+		mounts = append(mounts, TGFConfigMount{
+			Source:   "~/" + name,
+			Target:   path.Join(containerHome, name),
+			ReadOnly: !readWrite,
+			Optional: true,
+		})
+	}
+	return
+}
+
+func parseHomeMount(entry string) (name string, readWrite bool) {
+	name = entry
+	if strings.HasSuffix(entry, ":rw") {
+		name, readWrite = strings.TrimSuffix(entry, ":rw"), true
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(name), "~/"), "./"), "/")
+	return
+}
+
+func validateHomeMount(entry string) error {
+	name, _ := parseHomeMount(entry)
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(path.Clean(name), "../") {
+		return fmt.Errorf("invalid home mount '%s', it must be a path relative to the home folder", entry)
+	}
+	return nil
+}
+
+// mergeMounts adds the mounts to the list, a mount replaces any previous mount having the same target
+func mergeMounts(mounts []TGFConfigMount, source string, newMounts ...TGFConfigMount) []TGFConfigMount {
+	for _, m := range newMounts {
+		if source != "" {
+			m.source = source
+		}
+		replaced := false
+		for i := range mounts {
+			if mounts[i].Target == m.Target {
+				mounts[i], replaced = m, true
+				break
+			}
+		}
+		if !replaced {
+			mounts = append(mounts, m)
+		}
+	}
+	return mounts
+}
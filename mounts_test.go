@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMountsFromTwoLevelsOfTgfConfig(t *testing.T) {
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	currentDir, _ := os.Getwd()
+	subFolder := path.Join(tempDir, "sub-folder")
+	defer func() { assert.NoError(t, os.Chdir(currentDir)) }()
+	assert.NoError(t, os.Mkdir(subFolder, os.ModePerm))
+	assert.NoError(t, os.Chdir(subFolder))
+
+	assert.NoError(t, os.WriteFile(path.Join(tempDir, ".tgf.config"), []byte(String(`
+	mounts:
+	  - source: shared
+	    target: /shared
+	  - source: cache
+	    target: /cache
+	    type: volume
+	`).UnIndent().TrimSpace()), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(subFolder, ".tgf.config"), []byte(String(`
+	mounts:
+	  - source: data
+	    target: /shared
+	    read-only: true
+	`).UnIndent().TrimSpace()), 0644))
+
+	config := InitConfig(NewTestApplication(nil, true))
+
+	assert.Len(t, config.Mounts, 2)
+	assert.Equal(t, path.Join(subFolder, "data"), filepath.ToSlash(config.Mounts[0].HostPath()))
+	assert.True(t, config.Mounts[0].ReadOnly)
+	assert.Equal(t, "cache", config.Mounts[1].Source)
+	assert.Contains(t, config.String(), "target: /cache")
+}
+
+func TestMountArg(t *testing.T) {
+	t.Parallel()
+
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	tests := []struct {
+		name  string
+		mount TGFConfigMount
+		want  string
+	}{
+		{"Bind", TGFConfigMount{Source: tempDir, Target: "/data"}, "type=bind,source=" + filepath.ToSlash(tempDir) + ",target=/data"},
+		{"Read only", TGFConfigMount{Source: tempDir, Target: "/data", ReadOnly: true}, "type=bind,source=" + filepath.ToSlash(tempDir) + ",target=/data,readonly"},
+		{"Comma", TGFConfigMount{Source: filepath.Join(tempDir, "a,b"), Target: "/my data"}, `type=bind,"source=` + filepath.ToSlash(tempDir) + `/a,b",target=/my data`},
+		{"Volume", TGFConfigMount{Source: "cache", Target: "/cache", Type: "volume"}, "type=volume,source=cache,target=/cache"},
+		{"Anonymous volume", TGFConfigMount{Target: "/cache", Type: "volume"}, "type=volume,target=/cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.mount.Arg())
+		})
+	}
+}
+
+func TestMountValidate(t *testing.T) {
+	t.Parallel()
+
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	missing := filepath.Join(tempDir, "missing")
+	tests := []struct {
+		name    string
+		mount   TGFConfigMount
+		wantErr bool
+	}{
+		{"Valid", TGFConfigMount{Source: tempDir, Target: "/data"}, false},
+		{"Relative target", TGFConfigMount{Source: tempDir, Target: "data"}, true},
+		{"Missing source", TGFConfigMount{Source: missing, Target: "/data"}, true},
+		{"Optional missing source", TGFConfigMount{Source: missing, Target: "/data", Optional: true}, false},
+		{"No source", TGFConfigMount{Target: "/data"}, true},
+		{"Volume", TGFConfigMount{Source: "cache", Target: "/cache", Type: "volume"}, false},
+		{"Invalid volume", TGFConfigMount{Source: "/cache", Target: "/cache", Type: "volume"}, true},
+		{"Invalid type", TGFConfigMount{Source: tempDir, Target: "/data", Type: "tmpfs"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, tt.mount.validate() != nil)
+		})
+	}
+}
+
+func TestGetHomeMounts(t *testing.T) {
+	config := &TGFConfig{HomeMounts: []string{".aws", "~/.kube/", ".terraformrc:rw"}, tgf: &TGFApplication{}}
+
+	mounts := config.getHomeMounts("/home/jsmith")
+	assert.Equal(t, []TGFConfigMount{
+		{Source: "~/.aws", Target: "/home/jsmith/.aws", ReadOnly: true, Optional: true},
+		{Source: "~/.kube", Target: "/home/jsmith/.kube", ReadOnly: true, Optional: true},
+		{Source: "~/.terraformrc", Target: "/home/jsmith/.terraformrc", Optional: true},
+	}, mounts)
+
+	assert.Empty(t, config.getHomeMounts(""))
+	config.tgf.MountHomeDir = true
+	assert.Empty(t, config.getHomeMounts("/home/jsmith"))
+}
+
+func TestValidateHomeMount(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateHomeMount(".aws"))
+	assert.NoError(t, validateHomeMount(".ssh/known_hosts:rw"))
+	assert.Error(t, validateHomeMount("/etc/passwd"))
+	assert.Error(t, validateHomeMount("../other"))
+	assert.Error(t, validateHomeMount(""))
+}
+
+func TestGetMountArgsReplacesSameTarget(t *testing.T) {
+	t.Parallel()
+
+	args := getMountArgs(
+		TGFConfigMount{Source: "first", Target: "/data", Type: "volume"},
+		TGFConfigMount{Source: "other", Target: "/other", Type: "volume"},
+		TGFConfigMount{Source: "second", Target: "/data", Type: "volume"},
+	)
+	assert.Equal(t, []string{"--mount", "type=volume,source=second,target=/data", "--mount", "type=volume,source=other,target=/other"}, args)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	mountScopeFirstSegment = "first-segment"
+	mountScopeGitRoot      = "git-root"
+	mountScopeCwd          = "cwd"
+)
+
+// getMountRoot returns the host folder that should be mounted in the container according to the mount scope
+// The current folder must be an absolute path using slashes and the result is expressed the same way
+func getMountRoot(cwd, scope string) (string, error) {
+	drive := filepath.VolumeName(cwd) + "/"
+	switch scope {
+	case "", mountScopeFirstSegment:
+		return path.Join(drive, strings.Split(strings.TrimPrefix(cwd, drive), "/")[0]), nil
+	case mountScopeCwd:
+		return cwd, nil
+	case mountScopeGitRoot:
+		if root := findGitRoot(cwd); root != "" {
+			return root, nil
+		}
+		return "", fmt.Errorf("%s is not in a git repository", cwd)
+	}
+
+	root := expandPath(scope, cwd)
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	root = filepath.ToSlash(root)
+	if !isSubPath(root, cwd) {
+		return "", fmt.Errorf("the current folder %s is not under the mount scope %s", cwd, root)
+	}
+	return root, nil
+}
+
+// getMountFolders returns the folder where the mount root is mounted in the container and the working directory matching
+// the current folder. If the sources reference folders located up to levels parents above the mount root, the mount root is
+// placed under the names of its parent folders, so these folders can be mounted at the same relative position under the mount point.
+func getMountFolders(cwd, root, mountPoint string, levels int) (containerRoot, workDir string) {
+	containerRoot = path.Join("/", mountPoint)
+	if levels > 0 {
+		folders := strings.Split(strings.Trim(filepath.ToSlash(strings.TrimPrefix(root, filepath.VolumeName(root))), "/"), "/")
+		containerRoot = path.Join(append([]string{containerRoot}, folders[max(0, len(folders)-levels):]...)...)
+	}
+	workDir = path.Join(containerRoot, strings.TrimPrefix(cwd, root))
+	return
+}
+
+// findGitRoot returns the first folder containing a .git entry (folder or worktree file) starting from the folder
+func findGitRoot(folder string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(folder, ".git")); err == nil {
+			return folder
+		}
+		parent := path.Dir(folder)
+		if parent == folder || parent == "." {
+			return ""
+		}
+		folder = parent
+	}
+}
+
+// isSubPath returns true if the folder is the root or one of its descendants
+func isSubPath(root, folder string) bool {
+	root = strings.TrimSuffix(root, "/")
+	return folder == root || strings.HasPrefix(folder, root+"/")
+}
+
+func validateMountScope(scope string) error {
+	switch scope {
+	case "", mountScopeFirstSegment, mountScopeGitRoot, mountScopeCwd:
+		return nil
+	}
+	if info, err := os.Stat(expandPath(scope, must(os.Getwd()).(string))); err != nil {
+		return fmt.Errorf("invalid mount-scope %s, it must be %s, %s, %s or an existing folder: %v", scope, mountScopeFirstSegment, mountScopeGitRoot, mountScopeCwd, err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("invalid mount-scope %s, it is not a folder", scope)
+	}
+	return nil
+}
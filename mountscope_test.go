@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMountRoot(t *testing.T) {
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	tempDir = filepath.ToSlash(tempDir)
+	repo := tempDir + "/repo"
+	cwd := repo + "/live/prod"
+	assert.NoError(t, os.MkdirAll(cwd, 0755))
+	assert.NoError(t, os.Mkdir(repo+"/.git", 0755))
+	assert.NoError(t, os.Mkdir(tempDir+"/other", 0755))
+	drive := filepath.VolumeName(tempDir) + "/"
+	firstSegment := drive + strings.Split(strings.TrimPrefix(tempDir, drive), "/")[0]
+
+	tests := []struct {
+		name    string
+		scope   string
+		want    string
+		wantErr bool
+	}{
+		{"Default", "", firstSegment, false},
+		{"First segment", mountScopeFirstSegment, firstSegment, false},
+		{"Current folder", mountScopeCwd, cwd, false},
+		{"Git root", mountScopeGitRoot, repo, false},
+		{"Explicit", repo + "/live", repo + "/live", false},
+		{"Relative", "../..", repo, false},
+		{"Outside", tempDir + "/other", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getMountRoot(cwd, tt.scope)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := getMountRoot(tempDir, mountScopeGitRoot)
+	assert.Error(t, err)
+}
+
+func TestGetMountFolders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		cwd, root         string
+		levels            int
+		wantContainerRoot string
+		wantWorkDir       string
+	}{
+		{"First segment", "/home/jsmith/live/prod", "/home", 0, "/current_sources", "/current_sources/jsmith/live/prod"},
+		{"Current folder", "/home/jsmith/live/prod", "/home/jsmith/live/prod", 0, "/current_sources", "/current_sources"},
+		{"Windows", "C:/Users/jsmith/live", "C:/Users/jsmith", 0, "/current_sources", "/current_sources/live"},
+		{"Filesystem root", "/", "/", 0, "/current_sources", "/current_sources"},
+		{"Parent levels", "/home/jsmith/repo/live/prod", "/home/jsmith/repo/live/prod", 3, "/current_sources/repo/live/prod", "/current_sources/repo/live/prod"},
+		{"Parent levels on Windows", "C:/Users/jsmith/live", "C:/Users/jsmith", 1, "/current_sources/jsmith", "/current_sources/jsmith/live"},
+		{"Parent levels above the filesystem root", "/home/jsmith", "/home", 3, "/current_sources/home", "/current_sources/home/jsmith"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerRoot, workDir := getMountFolders(tt.cwd, tt.root, "current_sources", tt.levels)
+			assert.Equal(t, tt.wantContainerRoot, containerRoot)
+			assert.Equal(t, tt.wantWorkDir, workDir)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/coveooss/multilogger/errors"
+)
+
+// Folder of ~/.tgf containing the last version of the remote configuration files, they are used when tgf is offline
+const remoteConfigCacheFolder = "remote-config"
+
+// errOffline is returned when an operation requires a network access that is disabled by the offline mode
+var errOffline = fmt.Errorf("the network access is disabled by the offline mode")
+
+// getRemoteConfigCacheFile returns the file where the content of the remote configuration file is kept
+func getRemoteConfigCacheFile(source string) (string, error) {
+	filename, err := getTouchFilename(source)
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.Sum([]byte(source))
+	return filepath.Join(filepath.Dir(filename), remoteConfigCacheFolder, base64.RawURLEncoding.EncodeToString(hash[:])), nil
+}
+
+// readCachedRemoteConfig returns the content of the remote configuration file as it was when it was last fetched
+func readCachedRemoteConfig(source string) (string, error) {
+	filename, err := getRemoteConfigCacheFile(source)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filename)
+	return string(content), err
+}
+
+// mustReadCachedRemoteConfig returns the cached content of the remote configuration file, it fails if the file has never been fetched
+func mustReadCachedRemoteConfig(source string) string {
+	content, err := readCachedRemoteConfig(source)
+	if err != nil {
+		panic(errors.Managed(fmt.Sprintf("The configuration file %s has never been fetched, it cannot be used in offline mode", source)))
+	}
+	return content
+}
+
+// cacheRemoteConfig keeps the content of the remote configuration file to be able to use it offline
+func cacheRemoteConfig(source, content string) {
+	filename, err := getRemoteConfigCacheFile(source)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(filename), 0755); err == nil {
+			err = os.WriteFile(filename, []byte(content), 0644)
+		}
+	}
+	if err != nil {
+		log.Debugf("Unable to cache the configuration file %s: %v", source, err)
+	}
+}
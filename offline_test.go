@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindRemoteConfigFilesOffline(t *testing.T) {
+	location := t.TempDir() + "/"
+	source := location + remoteDefaultConfigPath
+	cacheFile, err := getRemoteConfigCacheFile(source)
+	assert.NoError(t, err)
+	defer os.Remove(cacheFile)
+
+	config := &TGFConfig{tgf: &TGFApplication{Offline: true}}
+	assert.PanicsWithError(t, "The configuration file "+source+" has never been fetched, it cannot be used in offline mode", func() {
+		config.findRemoteConfigFiles(location, "")
+	})
+
+	cacheRemoteConfig(source, "docker-image: coveo/tgf")
+	assert.Equal(t, []string{"docker-image: coveo/tgf"}, config.findRemoteConfigFiles(location, ""))
+}
+
+func TestFindRemoteConfigFilesUnreachable(t *testing.T) {
+	location := t.TempDir() + "/"
+	source := location + remoteDefaultConfigPath
+	cacheFile, err := getRemoteConfigCacheFile(source)
+	assert.NoError(t, err)
+	defer os.Remove(cacheFile)
+
+	// The file does not exist at the location, the cached version is used instead
+	config := &TGFConfig{tgf: &TGFApplication{}}
+	assert.Empty(t, config.findRemoteConfigFiles(location, ""))
+	cacheRemoteConfig(source, "docker-image: coveo/tgf")
+	assert.Equal(t, []string{"docker-image: coveo/tgf"}, config.findRemoteConfigFiles(location, ""))
+}
+
+func TestShouldUpdateOffline(t *testing.T) {
+	config := &TGFConfig{AutoUpdate: true, tgf: &TGFApplication{Offline: true, AutoUpdate: true, AutoUpdateSet: true}}
+	assert.False(t, config.ShouldUpdate())
+}
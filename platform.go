@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A platform is expressed as os/architecture with an optional variant (e.g. linux/amd64 or linux/arm64/v8)
+var rePlatform = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+// The architectures reported by the docker hosts are not always named like the go architectures used by the images
+var architectureAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+	"armhf":   "arm",
+	"i386":    "386",
+}
+
+func validatePlatform(platform string) error {
+	if platform != "" && !rePlatform.MatchString(platform) {
+		return fmt.Errorf("invalid docker-platform %s, it should be in the form os/architecture[/variant] (e.g. linux/amd64)", platform)
+	}
+	return nil
+}
+
+// getPlatformArgs returns the arguments selecting the platform of the image on docker build and docker run
+func (config *TGFConfig) getPlatformArgs() []string {
+	if config.Platform == "" {
+		return nil
+	}
+	return []string{"--platform", config.Platform}
+}
+
+// getPlatformTag returns the suffix added to the tag of the built images to distinguish the builds of different platforms
+func getPlatformTag(platform string) string {
+	if platform == "" {
+		return ""
+	}
+	return "-" + strings.ReplaceAll(platform, "/", "-")
+}
+
+// formatPlatform returns the platform as os/architecture[/variant]
+func formatPlatform(system, architecture, variant string) string {
+	if alias, found := architectureAliases[architecture]; found {
+		architecture = alias
+	}
+	platform := system + "/" + architecture
+	if variant != "" {
+		platform += "/" + variant
+	}
+	return platform
+}
+
+// isSamePlatform checks if the actual platform satisfies the expected one, the variant is only compared if it is expected
+func isSamePlatform(expected, actual string) bool {
+	expectedParts, actualParts := strings.Split(expected, "/"), strings.Split(actual, "/")
+	if len(expectedParts) < 2 || len(actualParts) < 2 {
+		return true
+	}
+	for i := range expectedParts {
+		if i >= len(actualParts) || expectedParts[i] != actualParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// getImagePlatform returns the platform of the local image, empty if the image is not available locally
+func getImagePlatform(image string) string {
+	summary := getImageSummary(image)
+	if summary == nil {
+		return ""
+	}
+	inspect := inspectImage(summary.ID)
+	return formatPlatform(inspect.Os, inspect.Architecture, inspect.Variant)
+}
+
+// getHostPlatform returns the platform of the docker host, empty if it cannot be determined
+func getHostPlatform() string {
+	cli, ctx := getDockerClient()
+	server, err := cli.ServerVersion(ctx)
+	if err != nil {
+		log.Debugf("Unable to get the platform of the docker host: %v", err)
+		return ""
+	}
+	return formatPlatform(server.Os, server.Arch, "")
+}
+
+// hasExpectedPlatform checks if the local image matches the configured platform, it must be pulled again otherwise
+// since the tag refers to all the platforms of the image
+func (docker *dockerConfig) hasExpectedPlatform(image string) bool {
+	if docker.Platform == "" {
+		return true
+	}
+	actual := getImagePlatform(image)
+	return actual == "" || isSamePlatform(docker.Platform, actual)
+}
+
+// checkImagePlatform warns the user when the local image is not built for the configured platform or, if none is configured,
+// for the platform of the docker host
+func (docker *dockerConfig) checkImagePlatform(image string) {
+	actual := getImagePlatform(image)
+	if actual == "" {
+		return
+	}
+	if docker.Platform != "" {
+		if !isSamePlatform(docker.Platform, actual) {
+			log.Warningf("The image %s is built for %s while docker-platform is %s", image, actual, docker.Platform)
+		}
+		return
+	}
+	if host := getHostPlatform(); host != "" && !isSamePlatform(host, actual) {
+		log.Warningf("The image %s is built for %s while the docker host runs %s, it may be slow or fail to run (see docker-platform)", image, actual, host)
+	}
+}
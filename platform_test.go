@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePlatform(t *testing.T) {
+	for _, platform := range []string{"", "linux/amd64", "linux/arm64/v8", "windows/amd64"} {
+		assert.NoError(t, validatePlatform(platform), platform)
+	}
+	for _, platform := range []string{"amd64", "linux/", "linux/amd64/v8/other", "Linux/AMD64"} {
+		assert.Error(t, validatePlatform(platform), platform)
+	}
+}
+
+func TestFormatPlatform(t *testing.T) {
+	assert.Equal(t, "linux/amd64", formatPlatform("linux", "amd64", ""))
+	assert.Equal(t, "linux/amd64", formatPlatform("linux", "x86_64", ""))
+	assert.Equal(t, "linux/arm64/v8", formatPlatform("linux", "aarch64", "v8"))
+}
+
+func TestIsSamePlatform(t *testing.T) {
+	tests := []struct {
+		expected string
+		actual   string
+		want     bool
+	}{
+		{"linux/amd64", "linux/amd64", true},
+		{"linux/amd64", "linux/arm64", false},
+		{"linux/arm64", "linux/arm64/v8", true},
+		{"linux/arm64/v8", "linux/arm64", false},
+		{"linux/arm/v7", "linux/arm/v6", false},
+		{"windows/amd64", "linux/amd64", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expected+"="+tt.actual, func(t *testing.T) {
+			assert.Equal(t, tt.want, isSamePlatform(tt.expected, tt.actual))
+		})
+	}
+}
+
+func TestGetPlatformArgs(t *testing.T) {
+	assert.Empty(t, (&TGFConfig{}).getPlatformArgs())
+	assert.Equal(t, []string{"--platform", "linux/arm64"}, (&TGFConfig{Platform: "linux/arm64"}).getPlatformArgs())
+	assert.Equal(t, "", getPlatformTag(""))
+	assert.Equal(t, "-linux-arm64-v8", getPlatformTag("linux/arm64/v8"))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	types_image "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"golang.org/x/term"
+)
+
+const (
+	defaultPullRetries = 3
+	defaultPullTimeout = 10 * time.Minute
+	pullBackoffInitial = 2 * time.Second
+	pullBackoffMax     = 30 * time.Second
+)
+
+// Messages returned by the registries when the credentials are missing, invalid or expired
+var pullAuthErrors = []string{"unauthorized", "authentication required", "no basic auth credentials", "denied", "expired"}
+
+// Messages returned when the image does not exist, there is no need to retry
+var pullNotFoundErrors = []string{"manifest unknown", "not found", "repository does not exist", "invalid reference format"}
+
+// pullImage pulls the image through the docker API and shows the progress, the transient errors are retried with an exponential backoff
+func (docker *dockerConfig) pullImage(image string) (err error) {
+	if docker.tgf.Offline {
+		return errOffline
+	}
+	ecrLogin := docker.renewECRLogin(image)
+	for attempt := 1; ; attempt++ {
+		auth, authErr := docker.getRegistryAuth(image)
+		if authErr != nil {
+			log.Warningf("Unable to get the registry credentials of %s: %v", image, authErr)
+		}
+		if err = pullImageAttempt(image, auth, docker.Platform, docker.PullTimeout); err == nil {
+			return
+		}
+		if isPullAuthError(err) && !ecrLogin && docker.useECRLogin(image) {
+			log.Debugf("Failed to pull %v. It is an ECR image, trying again after login to AWS ECR.", image)
+			if loginErr := docker.tryLoginToECR(image); loginErr != nil {
+				return fmt.Errorf("%v (the login to AWS ECR failed: %v)", err, loginErr)
+			}
+			ecrLogin = true
+			continue
+		}
+		if !isTransientPullError(err) || attempt > docker.PullRetries {
+			return
+		}
+		delay := getPullBackoff(attempt)
+		log.Warningf("Unable to pull %s (%v), retrying in %v (%d/%d)", image, err, delay, attempt, docker.PullRetries)
+		time.Sleep(delay)
+	}
+}
+
+// renewECRLogin logs in to AWS ECR if the image is hosted on ECR and the last token is missing or expired
+// This avoids a failing request to the registry with a stale token, loggedIn is true if a new token has been obtained.
+// A failed login is not fatal since the credentials already known by docker may still be valid.
+func (docker *dockerConfig) renewECRLogin(image string) (loggedIn bool) {
+	if !docker.useECRLogin(image) || isECRTokenValid(getECRTokenExpiry(image)) {
+		return false
+	}
+	log.Debugf("The ECR token of %v is missing or expired, login to AWS ECR", image)
+	if err := docker.tryLoginToECR(image); err != nil {
+		log.Warningf("Unable to login to AWS ECR for %v, trying with the current docker credentials: %v", image, err)
+		return false
+	}
+	return true
+}
+
+// pullImageAttempt pulls the image once, the progress is written on stderr
+func pullImageAttempt(image, auth, platform string, timeout time.Duration) error {
+	cli, ctx := getDockerClient()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	reader, err := cli.ImagePull(ctx, image, types_image.PullOptions{RegistryAuth: auth, Platform: platform})
+	if err == nil {
+		defer reader.Close()
+		fd := os.Stderr.Fd()
+		err = jsonmessage.DisplayJSONMessagesStream(reader, os.Stderr, fd, term.IsTerminal(int(fd)), nil)
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("the pull has timed out after %v", timeout)
+	}
+	return err
+}
+
+func isPullAuthError(err error) bool {
+	return errdefs.IsUnauthorized(err) || errdefs.IsForbidden(err) || containsAny(strings.ToLower(err.Error()), pullAuthErrors...)
+}
+
+// isTransientPullError returns true if the error may be caused by an unreachable or overloaded registry, so the pull can be retried
+func isTransientPullError(err error) bool {
+	if isPullAuthError(err) || errdefs.IsNotFound(err) || errdefs.IsInvalidParameter(err) {
+		return false
+	}
+	return !containsAny(strings.ToLower(err.Error()), pullNotFoundErrors...)
+}
+
+// getPullBackoff returns the delay before the next attempt, it doubles on each attempt up to pullBackoffMax
+func getPullBackoff(attempt int) time.Duration {
+	delay := pullBackoffInitial
+	for i := 1; i < attempt && delay < pullBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > pullBackoffMax {
+		delay = pullBackoffMax
+	}
+	return delay
+}
+
+func containsAny(s string, values ...string) bool {
+	for _, value := range values {
+		if strings.Contains(s, value) {
+			return true
+		}
+	}
+	return false
+}
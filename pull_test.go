@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPullBackoff(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 2*time.Second, getPullBackoff(1))
+	assert.Equal(t, 4*time.Second, getPullBackoff(2))
+	assert.Equal(t, 8*time.Second, getPullBackoff(3))
+	assert.Equal(t, pullBackoffMax, getPullBackoff(10))
+}
+
+func TestIsTransientPullError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		message   string
+		transient bool
+		auth      bool
+	}{
+		{`Get "https://registry-1.docker.io/v2/": dial tcp: lookup registry-1.docker.io: no such host`, true, false},
+		{"net/http: TLS handshake timeout", true, false},
+		{"toomanyrequests: You have reached your pull rate limit", true, false},
+		{"the pull has timed out after 10m0s", true, false},
+		{"manifest for coveo/tgf:9.9.9 not found: manifest unknown: manifest unknown", false, false},
+		{"pull access denied for coveo/private, repository does not exist or may require 'docker login'", false, true},
+		{"no basic auth credentials", false, true},
+		{"Your authorization token has expired. Reauthenticate and try again.", false, true},
+	}
+	for _, tt := range tests {
+		err := errors.New(tt.message)
+		assert.Equal(t, tt.transient, isTransientPullError(err), tt.message)
+		assert.Equal(t, tt.auth, isPullAuthError(err), tt.message)
+	}
+}
+
+func TestIsECRTokenValid(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, isECRTokenValid(time.Time{}), "Never logged in")
+	assert.False(t, isECRTokenValid(time.Now().Add(-time.Hour)), "Expired")
+	assert.False(t, isECRTokenValid(time.Now().Add(time.Minute)), "Expires during the pull")
+	assert.True(t, isECRTokenValid(time.Now().Add(12*time.Hour)))
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/coveooss/multilogger/errors"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// Server address used by the docker client to store the Docker Hub credentials
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// dockerConfigFile contains the credentials part of the docker client configuration (~/.docker/config.json)
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// Username used with the tokens when none is specified, most registries ignore it
+const defaultRegistryUsername = "token"
+
+// TGFConfigAuth contains the way to get the credentials of a docker registry
+type TGFConfigAuth struct {
+	Registry string `yaml:"registry,omitempty" json:"registry,omitempty" hcl:"registry,omitempty"`
+	Helper   string `yaml:"helper,omitempty" json:"helper,omitempty" hcl:"helper,omitempty"`
+	Username string `yaml:"username,omitempty" json:"username,omitempty" hcl:"username,omitempty"`
+	TokenEnv string `yaml:"token-env,omitempty" json:"token-env,omitempty" hcl:"token-env,omitempty"`
+	Command  string `yaml:"command,omitempty" json:"command,omitempty" hcl:"command,omitempty"`
+}
+
+func (ra TGFConfigAuth) validate() error {
+	if ra.Registry == "" {
+		return fmt.Errorf("the registry of a registry-auth entry is required")
+	}
+	count := 0
+	for _, value := range []string{ra.Helper, ra.TokenEnv, ra.Command} {
+		if value != "" {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("the registry-auth entry of %s must specify one of helper, token-env or command", ra.Registry)
+	}
+	return nil
+}
+
+// credentials returns the credentials of the registry from the credential helper, the environment variable or the command output
+func (ra TGFConfigAuth) credentials() (auth registry.AuthConfig, err error) {
+	if ra.Helper != "" {
+		return getHelperCredentials(ra.Helper, ra.Registry)
+	}
+
+	var token string
+	if ra.TokenEnv != "" {
+		if token = os.Getenv(ra.TokenEnv); token == "" {
+			return auth, fmt.Errorf("the token of %s is expected in %s, but it is not set", ra.Registry, ra.TokenEnv)
+		}
+	} else {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", ra.Command)
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", ra.Command)
+		}
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err = cmd.Run(); err != nil {
+			return auth, fmt.Errorf("unable to get the token of %s with %s: %v %s", ra.Registry, ra.Command, err, strings.TrimSpace(stderr.String()))
+		}
+		if token = strings.TrimSpace(stdout.String()); token == "" {
+			return auth, fmt.Errorf("the command %s returned an empty token for %s", ra.Command, ra.Registry)
+		}
+	}
+	auth.ServerAddress, auth.Username, auth.Password = ra.Registry, ra.Username, token
+	if auth.Username == "" {
+		auth.Username = defaultRegistryUsername
+	}
+	return
+}
+
+// getRegistryAuthEntry returns the registry-auth entry of the image registry
+func (config *TGFConfig) getRegistryAuthEntry(image string) (TGFConfigAuth, bool) {
+	server := normalizeRegistry(getRegistryServer(image))
+	for _, entry := range config.RegistryAuth {
+		if normalizeRegistry(entry.Registry) == server || server == normalizeRegistry(dockerHubServer) && normalizeRegistry(entry.Registry) == "docker.io" {
+			return entry, true
+		}
+	}
+	return TGFConfigAuth{}, false
+}
+
+// getRegistryAuth returns the encoded credentials of the image registry (as expected by the docker API), empty if there is no credential
+// The registry-auth entries have precedence over the credentials stored by docker login
+func (config *TGFConfig) getRegistryAuth(image string) (string, error) {
+	var auth registry.AuthConfig
+	var err error
+	if entry, found := config.getRegistryAuthEntry(image); found {
+		auth, err = entry.credentials()
+	} else {
+		auth, err = getDockerConfigAuth(getRegistryServer(image))
+	}
+	if err != nil || auth.Username == "" && auth.IdentityToken == "" {
+		return "", err
+	}
+	return registry.EncodeAuthConfig(auth)
+}
+
+// mergeRegistryAuth adds the entries to the list, an entry replaces any previous entry for the same registry
+func mergeRegistryAuth(entries []TGFConfigAuth, newEntries ...TGFConfigAuth) []TGFConfigAuth {
+	for _, entry := range newEntries {
+		replaced := false
+		for i := range entries {
+			if normalizeRegistry(entries[i].Registry) == normalizeRegistry(entry.Registry) {
+				entries[i], replaced = entry, true
+				break
+			}
+		}
+		if !replaced {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+var reDockerfileFrom = regexp.MustCompile(`(?im)^\s*FROM\s+(?:--\S+\s+)*(\S+)(?:\s+AS\s+(\S+))?`)
+
+// getDockerfileImages returns the images used by the FROM instructions of a Dockerfile (excluding the build stages and scratch)
+func getDockerfileImages(content string) (images []string) {
+	stages := map[string]bool{"scratch": true}
+	for _, match := range reDockerfileFrom.FindAllStringSubmatch(content, -1) {
+		if image := match[1]; !stages[strings.ToLower(image)] && !strings.Contains(image, "$") && !listContainsElement(images, image) {
+			images = append(images, image)
+		}
+		if match[2] != "" {
+			stages[strings.ToLower(match[2])] = true
+		}
+	}
+	return
+}
+
+// pullBuildImages pulls the images used by the Dockerfile whose registry has a registry-auth entry, since docker build only knows
+// the credentials stored by docker login. The images available locally are only pulled again if a refresh is requested.
+// It returns true if the build must not pull the images by itself.
+func (docker *dockerConfig) pullBuildImages(dockerfile string) (pulled bool) {
+	app := docker.tgf
+	refresh := app.Refresh && !app.UseLocalImage && !app.Offline
+	content, err := os.ReadFile(dockerfile)
+	if err != nil {
+		log.Debugf("Unable to read %s: %v", dockerfile, err)
+		return
+	}
+	for _, image := range getDockerfileImages(string(content)) {
+		if _, found := docker.getRegistryAuthEntry(image); !found {
+			continue
+		}
+		pulled = true
+		if !refresh && checkImage(image) {
+			continue
+		}
+		log.Debugf("Pulling %s used by %s with the registry-auth credentials", image, dockerfile)
+		if err := docker.pullImage(image); err != nil {
+			panic(errors.Managed(fmt.Sprintf("Unable to pull %s: %v", image, err)))
+		}
+	}
+	return
+}
+
+// getRegistryServer returns the registry of the image as it is named in the docker client configuration
+func getRegistryServer(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	if domain := reference.Domain(named); domain != "docker.io" {
+		return domain
+	}
+	return dockerHubServer
+}
+
+// getDockerConfigAuth returns the credentials of the registry stored by docker login (in the configuration file or a credential helper)
+func getDockerConfigAuth(server string) (auth registry.AuthConfig, err error) {
+	auth.ServerAddress = server
+	content, err := os.ReadFile(filepath.Join(getDockerConfigFolder(), "config.json"))
+	if os.IsNotExist(err) {
+		return auth, nil
+	} else if err != nil {
+		return
+	}
+	var config dockerConfigFile
+	if err = json.Unmarshal(content, &config); err != nil {
+		return
+	}
+
+	helper := config.CredsStore
+	if name, isSet := config.CredHelpers[server]; isSet {
+		helper = name
+	}
+	if helper != "" {
+		if auth, err = getHelperCredentials(helper, server); err != nil || auth.Username != "" || auth.IdentityToken != "" {
+			return
+		}
+	}
+
+	for name, entry := range config.Auths {
+		if normalizeRegistry(name) != normalizeRegistry(server) {
+			continue
+		}
+		auth.IdentityToken = entry.IdentityToken
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return auth, fmt.Errorf("invalid credentials for %s in the docker configuration: %v", server, err)
+			}
+			auth.Username, auth.Password, _ = strings.Cut(string(decoded), ":")
+		}
+		break
+	}
+	return auth, nil
+}
+
+// getHelperCredentials returns the credentials of the registry from a docker credential helper (docker-credential-<helper>)
+func getHelperCredentials(helper, server string) (auth registry.AuthConfig, err error) {
+	auth.ServerAddress = server
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = strings.NewReader(server), &stdout, &stderr
+	if err = cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stdout.String() + stderr.String()); strings.Contains(message, "credentials not found") {
+			return auth, nil
+		} else if message != "" {
+			err = fmt.Errorf("%s: %v", message, err)
+		}
+		return auth, fmt.Errorf("unable to get the credentials of %s from docker-credential-%s: %v", server, helper, err)
+	}
+	var credentials struct{ Username, Secret string }
+	if err = json.Unmarshal(stdout.Bytes(), &credentials); err != nil {
+		return
+	}
+	if credentials.Username == "<token>" {
+		auth.IdentityToken = credentials.Secret
+	} else {
+		auth.Username, auth.Password = credentials.Username, credentials.Secret
+	}
+	return
+}
+
+// normalizeRegistry removes the scheme and the path of a registry address (e.g. https://index.docker.io/v1/ => index.docker.io)
+func normalizeRegistry(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server, _, _ = strings.Cut(server, "/")
+	return strings.ToLower(server)
+}
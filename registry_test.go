@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRegistryServer(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, dockerHubServer, getRegistryServer("coveo/tgf:latest"))
+	assert.Equal(t, dockerHubServer, getRegistryServer("alpine"))
+	assert.Equal(t, "ghcr.io", getRegistryServer("ghcr.io/coveooss/tgf:1.2.3"))
+	assert.Equal(t, "localhost:5000", getRegistryServer("localhost:5000/tgf"))
+	assert.Equal(t, "123456789012.dkr.ecr.us-east-1.amazonaws.com", getRegistryServer("123456789012.dkr.ecr.us-east-1.amazonaws.com/tgf:latest"))
+}
+
+func TestNormalizeRegistry(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "index.docker.io", normalizeRegistry(dockerHubServer))
+	assert.Equal(t, "ghcr.io", normalizeRegistry("https://ghcr.io"))
+	assert.Equal(t, "localhost:5000", normalizeRegistry("localhost:5000"))
+}
+
+func TestGetDockerConfigAuth(t *testing.T) {
+	folder := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", folder)
+
+	auth, err := getDockerConfigAuth("ghcr.io")
+	assert.NoError(t, err)
+	assert.Equal(t, registry.AuthConfig{ServerAddress: "ghcr.io"}, auth, "No configuration file")
+
+	credentials := base64.StdEncoding.EncodeToString([]byte("jsmith:secret:with:colons"))
+	assert.NoError(t, os.WriteFile(filepath.Join(folder, "config.json"), []byte(`{
+		"auths": {
+			"https://ghcr.io": {"auth": "`+credentials+`"},
+			"https://index.docker.io/v1/": {"identitytoken": "token"}
+		}
+	}`), 0600))
+
+	auth, err = getDockerConfigAuth("ghcr.io")
+	assert.NoError(t, err)
+	assert.Equal(t, registry.AuthConfig{ServerAddress: "ghcr.io", Username: "jsmith", Password: "secret:with:colons"}, auth)
+
+	auth, err = getDockerConfigAuth(dockerHubServer)
+	assert.NoError(t, err)
+	assert.Equal(t, "token", auth.IdentityToken)
+
+	encoded, err := (&TGFConfig{}).getRegistryAuth("quay.io/coveo/tgf")
+	assert.NoError(t, err)
+	assert.Empty(t, encoded, "No credentials for the registry")
+}
+
+func TestRegistryAuthCredentials(t *testing.T) {
+	t.Setenv("TGF_TEST_REGISTRY_TOKEN", "ghp_123")
+
+	auth, err := TGFConfigAuth{Registry: "ghcr.io", TokenEnv: "TGF_TEST_REGISTRY_TOKEN"}.credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, registry.AuthConfig{ServerAddress: "ghcr.io", Username: defaultRegistryUsername, Password: "ghp_123"}, auth)
+
+	_, err = TGFConfigAuth{Registry: "ghcr.io", TokenEnv: "TGF_TEST_UNDEFINED_TOKEN"}.credentials()
+	assert.Error(t, err)
+
+	if runtime.GOOS != "windows" {
+		auth, err = TGFConfigAuth{Registry: "localhost:5000", Username: "robot$ci", Command: "echo '  secret  '"}.credentials()
+		assert.NoError(t, err)
+		assert.Equal(t, registry.AuthConfig{ServerAddress: "localhost:5000", Username: "robot$ci", Password: "secret"}, auth)
+
+		_, err = TGFConfigAuth{Registry: "localhost:5000", Command: "exit 1"}.credentials()
+		assert.Error(t, err)
+	}
+}
+
+func TestGetRegistryAuth(t *testing.T) {
+	t.Setenv("TGF_TEST_REGISTRY_TOKEN", "secret")
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	config := &TGFConfig{RegistryAuth: []TGFConfigAuth{
+		{Registry: "https://localhost:5000", Username: "jsmith", TokenEnv: "TGF_TEST_REGISTRY_TOKEN"},
+		{Registry: "docker.io", TokenEnv: "TGF_TEST_REGISTRY_TOKEN"},
+	}}
+	encoded, err := config.getRegistryAuth("localhost:5000/coveo/tgf:latest")
+	assert.NoError(t, err)
+	decoded, _ := base64.URLEncoding.DecodeString(encoded)
+	assert.JSONEq(t, `{"username": "jsmith", "password": "secret", "serveraddress": "https://localhost:5000"}`, string(decoded))
+
+	_, found := config.getRegistryAuthEntry("coveo/tgf")
+	assert.True(t, found, "docker.io matches the Docker Hub images")
+	_, found = config.getRegistryAuthEntry("ghcr.io/coveooss/tgf")
+	assert.False(t, found)
+}
+
+func TestRegistryAuthValidate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, TGFConfigAuth{Registry: "ghcr.io", TokenEnv: "GITHUB_TOKEN"}.validate())
+	assert.Error(t, TGFConfigAuth{TokenEnv: "GITHUB_TOKEN"}.validate())
+	assert.Error(t, TGFConfigAuth{Registry: "ghcr.io"}.validate())
+	assert.Error(t, TGFConfigAuth{Registry: "ghcr.io", TokenEnv: "GITHUB_TOKEN", Helper: "gcloud"}.validate())
+}
+
+func TestMergeRegistryAuth(t *testing.T) {
+	t.Parallel()
+
+	entries := mergeRegistryAuth(nil, TGFConfigAuth{Registry: "ghcr.io", TokenEnv: "A"}, TGFConfigAuth{Registry: "quay.io", TokenEnv: "B"})
+	entries = mergeRegistryAuth(entries, TGFConfigAuth{Registry: "https://ghcr.io", Helper: "gh"})
+	assert.Equal(t, []TGFConfigAuth{{Registry: "https://ghcr.io", Helper: "gh"}, {Registry: "quay.io", TokenEnv: "B"}}, entries)
+}
+
+func TestGetDockerfileImages(t *testing.T) {
+	t.Parallel()
+
+	dockerfile := `FROM coveo/tgf:1.2.3
+RUN echo "FROM ignored"
+FROM --platform=linux/amd64 ghcr.io/coveooss/tools:latest AS tools
+from tools as final
+FROM scratch
+FROM ${BASE}
+COPY --from=tools /usr/bin/tool /usr/bin/tool
+`
+	assert.Equal(t, []string{"coveo/tgf:1.2.3", "ghcr.io/coveooss/tools:latest"}, getDockerfileImages(dockerfile))
+}
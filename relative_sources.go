@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const terragruntConfigFile = "terragrunt.hcl"
+
+var (
+	reHclLocalReference = regexp.MustCompile(`(?m)^\s*(source|config_path)\s*=\s*"([^"]+)"`)
+	reHclPaths          = regexp.MustCompile(`(?ms)^\s*paths\s*=\s*\[(.*?)\]`)
+	reHclString         = regexp.MustCompile(`"([^"]+)"`)
+	reHclReadConfig     = regexp.MustCompile(`read_terragrunt_config\(\s*"([^"]+)"`)
+	reHclFindInParent   = regexp.MustCompile(`find_in_parent_folders\(\s*(?:"([^"]*)")?\s*[,)]`)
+)
+
+// findRelativeSources returns the host paths (using slashes) of the local files and folders referenced by the terragrunt
+// configuration files of the folder (module sources, dependencies, included and read configurations)
+// The dependencies are followed to also get the paths referenced by them
+func findRelativeSources(folder string) (result []string) {
+	found := make(map[string]bool)
+	scanned := make(map[string]bool)
+	add := func(p string) bool {
+		p = filepath.ToSlash(filepath.Clean(p))
+		if found[p] {
+			return false
+		}
+		found[p] = true
+		result = append(result, p)
+		return true
+	}
+
+	var scanFile func(file string)
+	scanFolder := func(folder string) {
+		files, _ := filepath.Glob(filepath.Join(folder, "*.hcl"))
+		for _, file := range files {
+			scanFile(file)
+		}
+	}
+	scanFile = func(file string) {
+		if scanned[file] {
+			return
+		}
+		scanned[file] = true
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return
+		}
+		dir := filepath.Dir(file)
+
+		for _, match := range reHclLocalReference.FindAllStringSubmatch(string(content), -1) {
+			if local, ok := localReference(dir, match[2]); ok {
+				if match[1] == "source" {
+					add(local)
+				} else if add(local) {
+					scanFolder(local)
+				}
+			}
+		}
+		for _, match := range reHclPaths.FindAllStringSubmatch(string(content), -1) {
+			for _, value := range reHclString.FindAllStringSubmatch(match[1], -1) {
+				if local, ok := localReference(dir, value[1]); ok && add(local) {
+					scanFolder(local)
+				}
+			}
+		}
+		for _, match := range reHclReadConfig.FindAllStringSubmatch(string(content), -1) {
+			if local, ok := localReference(dir, match[1]); ok {
+				add(local)
+				scanFile(local)
+			}
+		}
+		for _, match := range reHclFindInParent.FindAllStringSubmatch(string(content), -1) {
+			name := match[1]
+			if name == "" {
+				name = terragruntConfigFile
+			}
+			if parent := findInParentFolders(filepath.Dir(dir), name); parent != "" {
+				add(parent)
+				scanFile(parent)
+			}
+		}
+	}
+	scanFolder(folder)
+	return
+}
+
+// localReference returns the host path of a relative reference, the sub folder of module sources (after //) is ignored
+// since the whole module folder must be available
+func localReference(dir, value string) (string, bool) {
+	value = strings.TrimPrefix(value, "${get_terragrunt_dir()}/")
+	if !strings.HasPrefix(value, "./") && !strings.HasPrefix(value, "../") {
+		return "", false
+	}
+	if i := strings.Index(value, "//"); i >= 0 {
+		value = value[:i]
+	}
+	if strings.Contains(value, "${") {
+		// We cannot resolve interpolated values
+		return "", false
+	}
+	return filepath.Join(dir, value), true
+}
+
+func findInParentFolders(folder, name string) string {
+	for {
+		file := filepath.Join(folder, name)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+		parent := filepath.Dir(folder)
+		if parent == folder {
+			return ""
+		}
+		folder = parent
+	}
+}
+
+// getRelativeSourceMounts returns the mounts required to make the paths located outside the mounted root folder available in
+// the container at the same position relative to the working directory
+func getRelativeSourceMounts(paths []string, mountRoot, containerRoot string, readOnly bool) (mounts []TGFConfigMount) {
+	sort.Strings(paths)
+	var mounted []string
+	for _, p := range paths {
+		if isSubPath(mountRoot, p) || containsSubPath(mounted, p) {
+			continue
+		}
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		target, ok := getContainerPath(p, mountRoot, containerRoot)
+		if !ok {
+			log.Warningf("Unable to mount %s in the container at the same position relative to %s (consider changing mount-scope or --mount-point)", p, mountRoot)
+			continue
+		}
+		mounted = append(mounted, p)
+		mounts = append(mounts, TGFConfigMount{Source: p, Target: target, ReadOnly: readOnly})
+	}
+	return
+}
+
+// getParentLevels returns the highest number of parent folders of the mounted root folder traversed to reach the existing paths
+func getParentLevels(paths []string, mountRoot string) (levels int) {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(filepath.FromSlash(mountRoot), filepath.FromSlash(p)); err == nil {
+			levels = max(levels, countParentLevels(filepath.ToSlash(rel)))
+		}
+	}
+	return
+}
+
+// countParentLevels returns the number of .. at the beginning of the relative path
+func countParentLevels(rel string) (levels int) {
+	for rest := rel; rest == ".." || strings.HasPrefix(rest, "../"); rest = strings.TrimPrefix(rest[2:], "/") {
+		levels++
+	}
+	return
+}
+
+// Folders of the container that must never be hidden by a mount
+var containerSystemFolders = []string{"bin", "dev", "etc", "home", "lib", "lib64", "proc", "root", "run", "sbin", "sys", "tmp", "usr", "var"}
+
+// getContainerPath returns the container path matching the host path relatively to the mounted root folder
+// It fails if the path cannot be placed at the same relative position because it would be above the container root folder
+// or over a system folder
+func getContainerPath(hostPath, mountRoot, containerRoot string) (string, bool) {
+	rel, err := filepath.Rel(filepath.FromSlash(mountRoot), filepath.FromSlash(hostPath))
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if countParentLevels(rel) > len(strings.Split(strings.Trim(containerRoot, "/"), "/")) {
+		return "", false
+	}
+	target := path.Join(containerRoot, rel)
+	if target == "/" || listContainsElement(containerSystemFolders, strings.Split(target[1:], "/")[0]) {
+		return "", false
+	}
+	return target, true
+}
+
+func containsSubPath(roots []string, folder string) bool {
+	for _, root := range roots {
+		if isSubPath(root, folder) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatMounts(mounts []TGFConfigMount) string {
+	lines := make([]string, len(mounts))
+	for i, m := range mounts {
+		lines[i] = fmt.Sprintf("%s => %s", m.Source, m.Target)
+	}
+	return strings.Join(lines, "\n")
+}
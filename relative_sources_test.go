@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindRelativeSources(t *testing.T) {
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	tempDir = filepath.ToSlash(tempDir)
+	write := func(file, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(tempDir+"/"+file), 0755))
+		assert.NoError(t, os.WriteFile(tempDir+"/"+file, []byte(String(content).UnIndent().TrimSpace()), 0644))
+	}
+	write("live/terragrunt.hcl", `
+	locals {
+	  common = read_terragrunt_config("${get_terragrunt_dir()}/../shared/common.hcl")
+	}
+	`)
+	write("live/prod/app/terragrunt.hcl", `
+	include "root" {
+	  path = find_in_parent_folders()
+	}
+	terraform {
+	  source = "../../../modules//app"
+	}
+	dependency "vpc" {
+	  config_path = "../vpc"
+	}
+	dependencies {
+	  paths = ["../db", "../../shared-infra/dns"]
+	}
+	`)
+	write("live/prod/vpc/terragrunt.hcl", `
+	terraform {
+	  source = "git::https://github.com/acme/vpc.git//module?ref=v1.0.0"
+	}
+	dependency "network" {
+	  config_path = "../../../network"
+	}
+	`)
+	write("shared/common.hcl", `inputs = {}`)
+
+	assert.Equal(t, []string{
+		tempDir + "/modules",
+		tempDir + "/live/prod/vpc",
+		tempDir + "/network",
+		tempDir + "/live/prod/db",
+		tempDir + "/live/shared-infra/dns",
+		tempDir + "/live/terragrunt.hcl",
+		tempDir + "/shared/common.hcl",
+	}, findRelativeSources(tempDir+"/live/prod/app"))
+}
+
+func TestGetRelativeSourceMounts(t *testing.T) {
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	tempDir = filepath.ToSlash(tempDir)
+	for _, folder := range []string{"repo/live", "repo/modules", "modules/app", "modules/app/sub", "etc"} {
+		assert.NoError(t, os.MkdirAll(tempDir+"/"+folder, 0755))
+	}
+
+	paths := []string{
+		tempDir + "/repo/modules",
+		tempDir + "/modules/app/sub",
+		tempDir + "/modules/app",
+		tempDir + "/missing",
+		tempDir + "/etc",
+	}
+	assert.Equal(t, []TGFConfigMount{
+		{Source: tempDir + "/modules/app", Target: "/modules/app", ReadOnly: true},
+	}, getRelativeSourceMounts(paths, tempDir+"/repo", "/repo", true))
+
+	// The folders cannot be mounted if they would be above the container root
+	assert.Equal(t, []TGFConfigMount{
+		{Source: tempDir + "/repo/modules", Target: "/modules"},
+	}, getRelativeSourceMounts(paths, tempDir+"/repo/live", "/sources", false))
+}
+
+func TestGetParentLevels(t *testing.T) {
+	tempDir, _ := filepath.EvalSymlinks(t.TempDir())
+	tempDir = filepath.ToSlash(tempDir)
+	for _, folder := range []string{"repo/live/prod/app", "repo/modules/x", "modules"} {
+		assert.NoError(t, os.MkdirAll(tempDir+"/"+folder, 0755))
+	}
+
+	mountRoot := tempDir + "/repo/live/prod/app"
+	assert.Equal(t, 0, getParentLevels(nil, mountRoot))
+	assert.Equal(t, 0, getParentLevels([]string{mountRoot + "/sub"}, mountRoot))
+	assert.Equal(t, 3, getParentLevels([]string{tempDir + "/repo/modules", tempDir + "/repo/live"}, mountRoot))
+	assert.Equal(t, 3, getParentLevels([]string{tempDir + "/repo/modules", tempDir + "/missing"}, mountRoot), "The missing folders are ignored")
+	assert.Equal(t, 4, getParentLevels([]string{tempDir + "/modules"}, mountRoot))
+
+	// source = "../../../modules//x" with the current folder as mount scope
+	containerRoot, workDir := getMountFolders(mountRoot, mountRoot, "current_sources", getParentLevels([]string{tempDir + "/repo/modules"}, mountRoot))
+	assert.Equal(t, "/current_sources/live/prod/app", workDir)
+	assert.Equal(t, []TGFConfigMount{
+		{Source: tempDir + "/repo/modules", Target: "/current_sources/modules"},
+	}, getRelativeSourceMounts([]string{tempDir + "/repo/modules"}, mountRoot, containerRoot, false))
+}
+
+func TestGetContainerPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		hostPath      string
+		mountRoot     string
+		containerRoot string
+		want          string
+		wantOk        bool
+	}{
+		{"Sibling", "/home/jsmith/modules", "/home/jsmith/repo", "/current_sources", "/modules", true},
+		{"Inside", "/home/jsmith/repo/modules", "/home/jsmith/repo", "/current_sources", "/current_sources/modules", true},
+		{"Too far", "/home/modules", "/home/jsmith/repo", "/current_sources", "", false},
+		{"Deeper container root", "/home/modules", "/home/jsmith/repo", "/src/jsmith/repo", "/src/modules", true},
+		{"System folder", "/home/jsmith/etc", "/home/jsmith/repo", "/current_sources", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := getContainerPath(tt.hostPath, tt.mountRoot, tt.containerRoot)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
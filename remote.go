@@ -0,0 +1,368 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coveooss/multilogger/errors"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+const (
+	remoteSyncAuto   = "auto"
+	remoteSyncAlways = "always"
+	remoteSyncNever  = "never"
+)
+
+// Folders that are never synchronized to the remote docker host since they contain host specific binaries or caches
+var remoteSyncExcludes = []string{".terragrunt-cache", ".terraform"}
+
+// dockerEndpoint is the docker daemon address with the TLS settings of the docker context
+type dockerEndpoint struct {
+	Host          string
+	SkipTLSVerify bool
+	tlsFolder     string // Folder containing the TLS material of the context (ca.pem, cert.pem and key.pem)
+}
+
+// getDockerHost returns the docker daemon address from DOCKER_HOST or from the current docker context
+// An empty result means that the default local daemon is used
+func getDockerHost() string {
+	return getDockerEndpoint().Host
+}
+
+// getDockerEndpoint returns the docker daemon endpoint from DOCKER_HOST or from the current docker context
+// The TLS settings of DOCKER_HOST are defined by DOCKER_TLS_VERIFY and DOCKER_CERT_PATH, they are handled by the docker client
+func getDockerEndpoint() (endpoint dockerEndpoint) {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return dockerEndpoint{Host: host}
+	}
+
+	configDir := getDockerConfigFolder()
+	name := os.Getenv("DOCKER_CONTEXT")
+	if name == "" {
+		var dockerConfig struct{ CurrentContext string }
+		if content, err := os.ReadFile(filepath.Join(configDir, "config.json")); err == nil {
+			json.Unmarshal(content, &dockerConfig)
+		}
+		name = dockerConfig.CurrentContext
+	}
+	if name == "" || name == "default" {
+		return
+	}
+
+	var meta struct {
+		Endpoints map[string]dockerEndpoint
+	}
+	contextID := fmt.Sprintf("%x", sha256.Sum256([]byte(name)))
+	content, err := os.ReadFile(filepath.Join(configDir, "contexts", "meta", contextID, "meta.json"))
+	if err != nil {
+		log.Warningf("Unable to read docker context %s: %v", name, err)
+		return
+	}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		log.Warningf("Unable to read docker context %s: %v", name, err)
+		return
+	}
+	endpoint = meta.Endpoints["docker"]
+	folder := filepath.Join(configDir, "contexts", "tls", contextID, "docker")
+	if info, err := os.Stat(folder); err == nil && info.IsDir() {
+		endpoint.tlsFolder = folder
+	}
+	return
+}
+
+// getTLSConfig returns the TLS configuration of the endpoint built like the docker CLI does, nil if the context does not use TLS
+func (endpoint dockerEndpoint) getTLSConfig() (*tls.Config, error) {
+	if endpoint.tlsFolder == "" && !endpoint.SkipTLSVerify {
+		return nil, nil
+	}
+	options := tlsconfig.Options{InsecureSkipVerify: endpoint.SkipTLSVerify}
+	for file, option := range map[string]*string{"ca.pem": &options.CAFile, "cert.pem": &options.CertFile, "key.pem": &options.KeyFile} {
+		if _, err := os.Stat(filepath.Join(endpoint.tlsFolder, file)); endpoint.tlsFolder != "" && err == nil {
+			*option = filepath.Join(endpoint.tlsFolder, file)
+		}
+	}
+	return tlsconfig.Client(options)
+}
+
+// getDockerConfigFolder returns the folder containing the docker client configuration (config.json and contexts)
+func getDockerConfigFolder() string {
+	if folder := os.Getenv("DOCKER_CONFIG"); folder != "" {
+		return folder
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".docker")
+}
+
+// isRemoteDockerHost returns true if the docker daemon does not run on the current host (so host folders cannot be mounted)
+func isRemoteDockerHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "ssh":
+		return true
+	case "tcp", "http", "https":
+		switch u.Hostname() {
+		case "localhost", "127.0.0.1", "::1", "host.docker.internal":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// useRemoteSync returns true if the sources must be synchronized to a volume instead of being mounted
+func (config *TGFConfig) useRemoteSync() bool {
+	switch config.RemoteSync {
+	case remoteSyncAlways:
+		return true
+	case remoteSyncNever:
+		return false
+	}
+	return isRemoteDockerHost(getDockerHost())
+}
+
+// getSourcesVolume returns the name of the volume used to hold the sources of the folder on the remote docker host
+func getSourcesVolume(mountRoot string) string {
+	return fmt.Sprintf("%s-sources-%x", dockerVolumeName, sha1.Sum([]byte(mountRoot)))[:len(dockerVolumeName)+len("-sources-")+12]
+}
+
+// syncSources copies the content of the folder into a volume on the docker host and returns the volume name
+// The volume is recreated on each run to ensure that deleted files are not kept, the caller must hold the lock of the volume
+func syncSources(mountRoot, imageName string) string {
+	volume := getSourcesVolume(mountRoot)
+	cli, ctx := getDockerClient()
+	if err := cli.VolumeRemove(ctx, volume, true); err != nil && !errdefs.IsNotFound(err) {
+		panic(errors.Managed(fmt.Sprintf("Unable to remove volume %s holding the previous copy of %s (it may be used by a run from another host): %v", volume, mountRoot, err)))
+	}
+
+	log.Infof("Synchronizing %s to volume %s on the docker host", mountRoot, volume)
+	start := time.Now()
+	err := withVolumeContainer(volume, &container.Config{Image: imageName}, func(id string) error {
+		return cli.CopyToContainer(ctx, id, volumeMountPath, tarFolder(mountRoot, remoteSyncExcludes), container.CopyToContainerOptions{})
+	})
+	if err != nil {
+		panic(errors.Managed(fmt.Sprintf("Unable to synchronize %s to the docker host: %v", mountRoot, err)))
+	}
+	log.Debugf("Synchronization completed in %v", time.Since(start).Round(time.Millisecond))
+	touchLastUse(volume)
+	return volume
+}
+
+// syncBack copies the files matching the patterns from the working directory in the volume back to the current folder
+func syncBack(volume, imageName, workDir, cwd string, patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	err := withVolumeContainer(volume, &container.Config{Image: imageName}, func(id string) error {
+		cli, ctx := getDockerClient()
+		reader, _, err := cli.CopyFromContainer(ctx, id, path.Join(volumeMountPath, workDir))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		// The stream contains the caches created in the container, the files they contain must not be synchronized back
+		files, err := extractMatchingFiles(reader, cwd, patterns, remoteSyncExcludes)
+		for _, file := range files {
+			log.Debug("Synchronized back ", file)
+		}
+		return err
+	})
+	if err != nil {
+		log.Warningf("Unable to synchronize the files back from the docker host: %v", err)
+	}
+}
+
+// filterBindMounts removes the bind mounts that cannot be used with a remote docker host
+func filterBindMounts(mounts []TGFConfigMount) (result []TGFConfigMount) {
+	for _, m := range mounts {
+		if m.mountType() == mountTypeBind {
+			log.Warningf("%s cannot be mounted on %s with a remote docker host", m.Source, m.Target)
+			continue
+		}
+		result = append(result, m)
+	}
+	return
+}
+
+// tarFolder returns a tar stream of the content of the folder, the excluded folder names are skipped at any level
+func tarFolder(folder string, excludes []string) io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		archive := tar.NewWriter(writer)
+		err := filepath.Walk(folder, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if file == folder {
+				return nil
+			}
+			if info.IsDir() && listContainsElement(excludes, info.Name()) {
+				return filepath.SkipDir
+			}
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(file); err != nil {
+					return err
+				}
+			}
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(must(filepath.Rel(folder, file)).(string))
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(archive, f)
+			return err
+		})
+		if err == nil {
+			err = archive.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader
+}
+
+// extractMatchingFiles extracts the regular files whose name matches one of the patterns into the destination folder, the files
+// within the excluded folders are skipped. The first component of the entries (the name of the copied folder) is removed
+func extractMatchingFiles(reader io.Reader, destination string, patterns, excludes []string) (files []string, err error) {
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		_, name, found := strings.Cut(path.Clean(header.Name), "/")
+		if !found || name == ".." || strings.HasPrefix(name, "../") || !matchAny(path.Base(name), patterns) || isExcluded(name, excludes) {
+			continue
+		}
+		target := filepath.Join(destination, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return files, err
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return files, err
+		}
+		if err := os.WriteFile(target, content, os.FileMode(header.Mode).Perm()); err != nil {
+			return files, err
+		}
+		files = append(files, target)
+	}
+}
+
+// isExcluded returns true if one of the folders of the path is excluded
+func isExcluded(name string, excludes []string) bool {
+	folders := strings.Split(name, "/")
+	for _, folder := range folders[:len(folders)-1] {
+		if listContainsElement(excludes, folder) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sshDialer returns a dialer reaching the docker daemon of the ssh host through docker system dial-stdio
+// like the docker CLI does
+func sshDialer(host string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		u, err := url.Parse(host)
+		if err != nil {
+			return nil, err
+		}
+		args := []string{}
+		if u.User != nil {
+			args = append(args, "-l", u.User.Username())
+		}
+		if u.Port() != "" {
+			args = append(args, "-p", u.Port())
+		}
+		args = append(args, "--", u.Hostname(), "docker", "system", "dial-stdio")
+		cmd := exec.CommandContext(ctx, "ssh", args...)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return &commandConn{cmd, stdin, stdout}, nil
+	}
+}
+
+// commandConn implements net.Conn over the standard input and output of a command
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *commandConn) Read(b []byte) (int, error)  { return c.stdout.Read(b) }
+func (c *commandConn) Write(b []byte) (int, error) { return c.stdin.Write(b) }
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
+func (c *commandConn) LocalAddr() net.Addr                { return dummyAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr               { return dummyAddr{} }
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type dummyAddr struct{}
+
+func (dummyAddr) Network() string { return "dummy" }
+func (dummyAddr) String() string  { return "dummy" }
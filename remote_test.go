@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRemoteDockerHost(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"", false},
+		{"unix:///var/run/docker.sock", false},
+		{"npipe:////./pipe/docker_engine", false},
+		{"tcp://localhost:2375", false},
+		{"tcp://127.0.0.1:2376", false},
+		{"tcp://builder.acme.com:2376", true},
+		{"ssh://jsmith@builder.acme.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRemoteDockerHost(tt.host))
+		})
+	}
+}
+
+func TestGetDockerHost(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	assert.Equal(t, "", getDockerHost())
+
+	metaDir := filepath.Join(configDir, "contexts", "meta", fmt.Sprintf("%x", sha256.Sum256([]byte("builder"))))
+	assert.NoError(t, os.MkdirAll(metaDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(`{"Name":"builder","Endpoints":{"docker":{"Host":"ssh://builder.acme.com"}}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"builder"}`), 0644))
+	assert.Equal(t, "ssh://builder.acme.com", getDockerHost())
+
+	t.Setenv("DOCKER_CONTEXT", "default")
+	assert.Equal(t, "", getDockerHost())
+
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:2375")
+	assert.Equal(t, "tcp://127.0.0.1:2375", getDockerHost())
+}
+
+func TestGetDockerEndpointTLS(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "remote")
+
+	contextID := fmt.Sprintf("%x", sha256.Sum256([]byte("remote")))
+	metaDir := filepath.Join(configDir, "contexts", "meta", contextID)
+	assert.NoError(t, os.MkdirAll(metaDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(`{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://builder.acme.com:2376"}}}`), 0644))
+	tlsConfig, err := getDockerEndpoint().getTLSConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig, "The context does not use TLS")
+
+	// The TLS material of the context is used, the certificates must be valid
+	tlsDir := filepath.Join(configDir, "contexts", "tls", contextID, "docker")
+	assert.NoError(t, os.MkdirAll(tlsDir, 0700))
+	endpoint := getDockerEndpoint()
+	assert.Equal(t, tlsDir, endpoint.tlsFolder)
+	tlsConfig, err = endpoint.getTLSConfig()
+	assert.NoError(t, err)
+	assert.NotNil(t, tlsConfig)
+	assert.NoError(t, os.WriteFile(filepath.Join(tlsDir, "ca.pem"), []byte("invalid"), 0600))
+	_, err = endpoint.getTLSConfig()
+	assert.Error(t, err)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(`{"Name":"remote","Endpoints":{"docker":{"Host":"tcp://builder.acme.com:2376","SkipTLSVerify":true}}}`), 0644))
+	assert.NoError(t, os.RemoveAll(tlsDir))
+	tlsConfig, err = getDockerEndpoint().getTLSConfig()
+	assert.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+}
+
+func TestSyncArchives(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	for file, content := range map[string]string{
+		"sources/main.tf":                                      "resource",
+		"sources/live/.terraform.lock.hcl":                     "lock",
+		"sources/live/.terraform/providers/provider":           "binary",
+		"sources/live/.terragrunt-cache/x/main.tf":             "cache",
+		"sources/live/.terragrunt-cache/x/.terraform.lock.hcl": "cached lock",
+	} {
+		file = filepath.Join(source, file)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	}
+
+	destination := t.TempDir()
+	reader := tarFolder(source, remoteSyncExcludes)
+	defer reader.Close()
+	files, err := extractMatchingFiles(reader, destination, []string{"*.lock.hcl", "*.tf"}, nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(destination, "main.tf"), filepath.Join(destination, "live", ".terraform.lock.hcl")}, files)
+
+	content, err := os.ReadFile(filepath.Join(destination, "live", ".terraform.lock.hcl"))
+	assert.NoError(t, err)
+	assert.Equal(t, "lock", string(content))
+
+	// The folder copied from the container contains the caches
+	destination = t.TempDir()
+	reader = tarFolder(source, nil)
+	defer reader.Close()
+	files, err = extractMatchingFiles(reader, destination, []string{"*.lock.hcl"}, remoteSyncExcludes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(destination, "live", ".terraform.lock.hcl")}, files)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+)
+
+var reNetworkName = regexp.MustCompile(`^(?:container:)?[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// getResourceArgs returns the docker arguments for the configured resource limits and network settings
+func (config *TGFConfig) getResourceArgs() (args []string) {
+	if config.CPUs != "" {
+		args = append(args, "--cpus="+config.CPUs)
+	}
+	if config.Memory != "" {
+		args = append(args, "--memory="+config.Memory)
+	}
+	if config.Network != "" {
+		args = append(args, "--network="+config.Network)
+	}
+	for _, dns := range config.DNS {
+		args = append(args, "--dns="+dns)
+	}
+	for _, host := range config.AddHosts {
+		args = append(args, "--add-host="+host)
+	}
+	for _, publish := range config.Publish {
+		args = append(args, "--publish="+publish)
+	}
+	return
+}
+
+func (config *TGFConfig) validateResources() (errors []error) {
+	if config.CPUs != "" {
+		if cpus, err := strconv.ParseFloat(config.CPUs, 64); err != nil || cpus <= 0 {
+			errors = append(errors, fmt.Errorf("invalid cpus %s, it must be a positive number", config.CPUs))
+		}
+	}
+	if config.Memory != "" {
+		if _, err := units.RAMInBytes(config.Memory); err != nil {
+			errors = append(errors, fmt.Errorf("invalid memory %s: %v", config.Memory, err))
+		}
+	}
+	if config.Network != "" && !reNetworkName.MatchString(config.Network) {
+		errors = append(errors, fmt.Errorf("invalid network %s", config.Network))
+	}
+	for _, dns := range config.DNS {
+		if net.ParseIP(dns) == nil {
+			errors = append(errors, fmt.Errorf("invalid dns %s, it must be an IP address", dns))
+		}
+	}
+	for _, host := range config.AddHosts {
+		if name, ip, found := strings.Cut(host, ":"); !found || name == "" || (ip != "host-gateway" && net.ParseIP(ip) == nil) {
+			errors = append(errors, fmt.Errorf("invalid add-hosts entry %s, it must be in the form <host>:<ip>", host))
+		}
+	}
+	for _, publish := range config.Publish {
+		if _, err := nat.ParsePortSpec(publish); err != nil {
+			errors = append(errors, fmt.Errorf("invalid publish entry %s: %v", publish, err))
+		}
+	}
+	return
+}
+
+// mergeValues adds the values that are not already in the list
+func mergeValues(values []string, newValues ...string) []string {
+	for _, value := range newValues {
+		if !listContainsElement(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
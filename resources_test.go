@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetResourceArgs(t *testing.T) {
+	t.Parallel()
+
+	config := &TGFConfig{
+		CPUs:     "1.5",
+		Memory:   "2g",
+		Network:  "host",
+		DNS:      []string{"10.0.0.2", "8.8.8.8"},
+		AddHosts: []string{"vault.local:10.0.0.10"},
+		Publish:  []string{"8080:80"},
+	}
+	assert.Equal(t, []string{
+		"--cpus=1.5",
+		"--memory=2g",
+		"--network=host",
+		"--dns=10.0.0.2",
+		"--dns=8.8.8.8",
+		"--add-host=vault.local:10.0.0.10",
+		"--publish=8080:80",
+	}, config.getResourceArgs())
+	assert.Empty(t, config.validateResources())
+	assert.Empty(t, (&TGFConfig{}).getResourceArgs())
+}
+
+func TestValidateResources(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config TGFConfig
+	}{
+		{"Invalid cpus", TGFConfig{CPUs: "two"}},
+		{"Negative cpus", TGFConfig{CPUs: "-1"}},
+		{"Invalid memory", TGFConfig{Memory: "lots"}},
+		{"Invalid network", TGFConfig{Network: "my network"}},
+		{"Invalid dns", TGFConfig{DNS: []string{"dns.acme.com"}}},
+		{"Invalid host", TGFConfig{AddHosts: []string{"vault.local"}}},
+		{"Invalid publish", TGFConfig{Publish: []string{"http"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, tt.config.validateResources(), 1)
+		})
+	}
+	assert.Empty(t, (&TGFConfig{AddHosts: []string{"host.docker.internal:host-gateway"}, Network: "container:db"}).validateResources())
+}
+
+func TestMergeValues(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"a", "b", "c"}, mergeValues([]string{"a", "b"}, "b", "c"))
+	assert.Nil(t, mergeValues(nil))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	dryRunScript = "script"
+	dryRunJSON   = "json"
+)
+
+// dockerRun contains the docker run invocation computed from the configuration
+type dockerRun struct {
+	Image       string           `json:"image"`
+	Command     []string         `json:"command"`
+	WorkDir     string           `json:"workdir"`
+	Mounts      []TGFConfigMount `json:"mounts"`
+	Environment []string         `json:"environment"`
+	Args        []string         `json:"args"`
+	Notes       []string         `json:"notes,omitempty"`
+
+	sources  TGFConfigMount    // Mount of the source folder
+	exported map[string]string // Variables defined by tgf that must be exported before running docker
+	isSecret func(string) bool
+	cleanups []func()
+	after    []func()
+}
+
+// cleanup removes the temporary resources created to prepare the run
+func (run *dockerRun) cleanup() {
+	for _, cleanup := range run.cleanups {
+		cleanup()
+	}
+}
+
+// print outputs the invocation in the requested format
+func (run *dockerRun) print(format string) int {
+	if format == dryRunJSON {
+		fmt.Println(string(must(json.MarshalIndent(run, "", "  ")).([]byte)))
+	} else {
+		fmt.Print(run.script())
+	}
+	return 0
+}
+
+// script returns a shell script reproducing the invocation, the secrets are referenced instead of being written in the script
+func (run *dockerRun) script() string {
+	var lines []string
+	lines = append(lines, "#!/usr/bin/env sh", fmt.Sprintf("# Generated by tgf %s", version))
+	for _, note := range run.Notes {
+		lines = append(lines, "# "+note)
+	}
+	lines = append(lines, "")
+
+	names := make([]string, 0, len(run.exported))
+	for name := range run.exported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if run.isSecret != nil && run.isSecret(name) {
+			lines = append(lines, fmt.Sprintf(`export %s="${%s:?}"`, name, name))
+		} else {
+			lines = append(lines, fmt.Sprintf("export %s=%s", name, shellQuote(run.exported[name])))
+		}
+	}
+
+	args := make([]string, len(run.Args))
+	for i := range run.Args {
+		args[i] = shellQuote(run.Args[i])
+	}
+	lines = append(lines, "exec docker "+strings.Join(args, " "))
+	return strings.Join(lines, "\n") + "\n"
+}
+
+var reShellSafe = regexp.MustCompile(`^[a-zA-Z0-9_./:=@%+,-]+$`)
+
+// shellQuote returns the value quoted for a POSIX shell when required
+func shellQuote(value string) string {
+	if reShellSafe.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
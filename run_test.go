@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellQuote(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "--mount", shellQuote("--mount"))
+	assert.Equal(t, "type=bind,source=/home/jsmith,target=/current_sources", shellQuote("type=bind,source=/home/jsmith,target=/current_sources"))
+	assert.Equal(t, "'my folder'", shellQuote("my folder"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	assert.Equal(t, "''", shellQuote(""))
+}
+
+func TestDockerRunScript(t *testing.T) {
+	t.Parallel()
+
+	config := &TGFConfig{SecretVariables: []string{"GITHUB_TOKEN"}}
+	run := &dockerRun{
+		Image:    "coveo/tgf:latest",
+		Command:  []string{"terragrunt", "plan"},
+		Args:     []string{"run", "-w", "/current_sources", "-e", "GITHUB_TOKEN", "-e", "TGF_ARGS", "--rm", "coveo/tgf:latest", "terragrunt", "plan"},
+		Notes:    []string{"Something is missing"},
+		exported: map[string]string{"TGF_ARGS": "tgf plan -D", "GITHUB_TOKEN": "ghp_123", "AWS_SESSION_TOKEN": "token"},
+		isSecret: config.isSecretVariable,
+	}
+	script := run.script()
+	assert.Equal(t, `#!/usr/bin/env sh
+# Generated by tgf `+version+`
+# Something is missing
+
+export AWS_SESSION_TOKEN="${AWS_SESSION_TOKEN:?}"
+export GITHUB_TOKEN="${GITHUB_TOKEN:?}"
+export TGF_ARGS='tgf plan -D'
+exec docker run -w /current_sources -e GITHUB_TOKEN -e TGF_ARGS --rm coveo/tgf:latest terragrunt plan
+`, script)
+	assert.NotContains(t, script, "ghp_123")
+
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(must(json.Marshal(run)).([]byte), &result))
+	assert.Equal(t, "coveo/tgf:latest", result["image"])
+	assert.NotContains(t, string(must(json.Marshal(run)).([]byte)), "ghp_123")
+}
+
+func TestGetBuildImageName(t *testing.T) {
+	t.Parallel()
+
+	ib := TGFConfigBuild{Instructions: "RUN ls", Tag: "custom"}
+	name, lastHash := getBuildImageName("coveo/tgf:1.2.3", "", "", ib)
+	assert.Equal(t, "coveo/tgf:1.2.3-custom", name)
+	assert.Equal(t, "-"+ib.hash(), lastHash)
+
+	name, _ = getBuildImageName("coveo/tgf@sha256:"+"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "", "", ib)
+	assert.Equal(t, "coveo/tgf:sha256-0123456789ab-custom", name)
+	name, _ = getBuildImageName("coveo/tgf@sha256:0123", "", "", ib)
+	assert.Equal(t, "coveo/tgf:sha256-0123-custom", name, "A short digest must not cause a panic")
+
+	// The platform stays at the end of the tag when the builds are chained
+	other := TGFConfigBuild{Instructions: "RUN pwd", Tag: "other"}
+	name, lastHash = getBuildImageName("coveo/tgf:1.2.3", "", "linux/arm64", ib)
+	assert.Equal(t, "coveo/tgf:1.2.3-custom-linux-arm64", name)
+	name, _ = getBuildImageName(name, lastHash, "linux/arm64", other)
+	assert.Equal(t, "coveo/tgf:1.2.3-custom-other-linux-arm64", name)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/coveooss/gotemplate/v3/collections"
+)
+
+const (
+	secretsTarget           = "/run/tgf-secrets"
+	secretsPattern          = "tgf-secrets"
+	awsCredentialsFile      = "aws-credentials"
+	awsSharedCredentialsVar = "AWS_SHARED_CREDENTIALS_FILE"
+)
+
+// The AWS credentials are always considered as secrets
+var awsSecretVariables = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"}
+
+// isSecretVariable returns true if the variable contains a secret that should never be displayed
+func (config *TGFConfig) isSecretVariable(name string) bool {
+	return listContainsElement(awsSecretVariables, name) || listContainsElement(config.SecretVariables, name)
+}
+
+// getSecretMounts writes the secrets in files on an in-memory file system (if available) and returns the mount of the folder
+// containing them. The secret variables are replaced by variables pointing to the files (AWS_SHARED_CREDENTIALS_FILE for the
+// AWS credentials and <name>_FILE for the other secrets) and removed from the environment passed to the container.
+// The returned function removes the files and must be called once the container is terminated.
+// The owner (uid[:gid]) is the user of the container if it is neither root nor the current user, the files must be given to this user.
+// If it is not possible, the secrets are kept in the environment rather than being made readable by the other users of the host.
+func (config *TGFConfig) getSecretMounts(owner string) (mounts []TGFConfigMount, cleanup func()) {
+	cleanup = func() {}
+	if !config.SecretsAsFiles {
+		return
+	}
+
+	secrets := make(map[string]string)
+	for _, name := range append(append([]string{}, awsSecretVariables...), config.SecretVariables...) {
+		if value, ok := config.Environment[name]; ok {
+			secrets[name] = value
+		} else if value, ok := os.LookupEnv(name); ok {
+			secrets[name] = value
+		}
+	}
+	if len(secrets) == 0 {
+		return
+	}
+
+	folder := must(os.MkdirTemp(getSecretsBaseFolder(), secretsPattern)).(string)
+	cleanup = func() { os.RemoveAll(folder) }
+
+	var files []string
+	for file, content := range getSecretFiles(secrets) {
+		files = append(files, filepath.Join(folder, file))
+		must(os.WriteFile(files[len(files)-1], []byte(content), 0600))
+	}
+	if owner != "" && !shareSecrets(owner, append([]string{folder}, files...)...) {
+		log.Warningf("The secrets cannot be given to the user %s of the image, they are delivered as environment variables (use --with-current-user to deliver them as files)", owner)
+		cleanup()
+		return nil, func() {}
+	}
+	for name := range secrets {
+		delete(config.Environment, name)
+		os.Unsetenv(name)
+		if !listContainsElement(awsSecretVariables, name) {
+			config.Environment[name+"_FILE"] = path.Join(secretsTarget, name)
+		}
+	}
+	if _, ok := secrets["AWS_ACCESS_KEY_ID"]; ok {
+		config.Environment[awsSharedCredentialsVar] = path.Join(secretsTarget, awsCredentialsFile)
+		// The profile must not be redefined since the credentials file only contains the default profile
+		delete(config.Environment, "AWS_PROFILE")
+		os.Unsetenv("AWS_PROFILE")
+	}
+
+	log.Debugf("Secrets %s are available in %s", collections.AsDictionary(secrets).KeysAsString().Join(", "), secretsTarget)
+	mounts = append(mounts, TGFConfigMount{Source: folder, Target: secretsTarget, ReadOnly: true})
+	return
+}
+
+// shareSecrets gives the files to the user of the container (uid[:gid]), it is only possible if tgf is allowed to change their owner.
+// The permissions are never extended to the other users of the host, false is returned if the files cannot be given to the user.
+func shareSecrets(owner string, files ...string) bool {
+	uid, gid, _ := strings.Cut(owner, ":")
+	userID, err := strconv.Atoi(uid)
+	if err != nil {
+		return false
+	}
+	groupID, err := strconv.Atoi(gid)
+	if err != nil {
+		groupID = -1
+	}
+	for _, file := range files {
+		if err := os.Chown(file, userID, groupID); err != nil {
+			log.Debugf("Unable to give %s to %s: %v", file, owner, err)
+			return false
+		}
+	}
+	return true
+}
+
+// getSecretFiles returns the content of the files (by name) used to deliver the secrets
+func getSecretFiles(secrets map[string]string) map[string]string {
+	files := make(map[string]string)
+	if id, ok := secrets["AWS_ACCESS_KEY_ID"]; ok {
+		content := fmt.Sprintf("[default]\naws_access_key_id = %s\naws_secret_access_key = %s\n", id, secrets["AWS_SECRET_ACCESS_KEY"])
+		if token := secrets["AWS_SESSION_TOKEN"]; token != "" {
+			content += fmt.Sprintf("aws_session_token = %s\n", token)
+		}
+		files[awsCredentialsFile] = content
+	}
+	for name, value := range secrets {
+		if !listContainsElement(awsSecretVariables, name) {
+			files[name] = value
+		}
+	}
+	return files
+}
+
+var secretsOnDiskWarning sync.Once
+
+// getSecretsBaseFolder returns the in-memory file system if available to avoid writing the secrets on disk
+func getSecretsBaseFolder() string {
+	if runtime.GOOS == "linux" {
+		if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+			return "/dev/shm"
+		}
+	}
+	secretsOnDiskWarning.Do(func() {
+		log.Warningf("There is no in-memory file system, the secret files are written on disk in %s until the container exits", os.TempDir())
+	})
+	return os.TempDir()
+}
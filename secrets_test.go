@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSecretFiles(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, map[string]string{
+		awsCredentialsFile: "[default]\naws_access_key_id = AKIA\naws_secret_access_key = secret\naws_session_token = token\n",
+		"GITHUB_TOKEN":     "ghp_123",
+	}, getSecretFiles(map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKIA",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_SESSION_TOKEN":     "token",
+		"GITHUB_TOKEN":          "ghp_123",
+	}))
+	assert.Equal(t, map[string]string{
+		awsCredentialsFile: "[default]\naws_access_key_id = AKIA\naws_secret_access_key = secret\n",
+	}, getSecretFiles(map[string]string{"AWS_ACCESS_KEY_ID": "AKIA", "AWS_SECRET_ACCESS_KEY": "secret"}))
+}
+
+func TestGetSecretMounts(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	os.Unsetenv("AWS_ACCESS_KEY_ID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	os.Unsetenv("AWS_SESSION_TOKEN")
+	t.Setenv("GITHUB_TOKEN", "ghp_123")
+
+	config := &TGFConfig{
+		SecretVariables: []string{"GITHUB_TOKEN", "NOT_DEFINED"},
+		Environment:     map[string]string{"AWS_REGION": "us-east-1"},
+	}
+	mounts, cleanup := config.getSecretMounts("")
+	assert.Empty(t, mounts, "Secrets are only delivered as files if requested")
+	cleanup()
+
+	config.SecretsAsFiles = true
+	mounts, cleanup = config.getSecretMounts("")
+	assert.Len(t, mounts, 1)
+	folder := mounts[0].Source
+	content, err := os.ReadFile(filepath.Join(folder, "GITHUB_TOKEN"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ghp_123", string(content))
+	assert.Equal(t, map[string]string{"AWS_REGION": "us-east-1", "GITHUB_TOKEN_FILE": "/run/tgf-secrets/GITHUB_TOKEN"}, config.Environment)
+	_, defined := os.LookupEnv("GITHUB_TOKEN")
+	assert.False(t, defined)
+
+	cleanup()
+	_, err = os.Stat(folder)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIsSecretVariable(t *testing.T) {
+	t.Parallel()
+
+	config := &TGFConfig{SecretVariables: []string{"GITHUB_TOKEN"}}
+	assert.True(t, config.isSecretVariable("AWS_SESSION_TOKEN"))
+	assert.True(t, config.isSecretVariable("GITHUB_TOKEN"))
+	assert.False(t, config.isSecretVariable("AWS_REGION"))
+}
+
+func TestShareSecrets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The file permissions are not supported on Windows")
+	}
+	t.Parallel()
+
+	folder, err := os.MkdirTemp(t.TempDir(), secretsPattern)
+	assert.NoError(t, err)
+	file := filepath.Join(folder, "secret")
+	assert.NoError(t, os.WriteFile(file, []byte("secret"), 0600))
+
+	assert.False(t, shareSecrets("jsmith", folder, file), "The user must be resolved to its uid")
+	assert.Equal(t, os.Getuid() == 0, shareSecrets("1234:1234", folder, file), "Only root can give the files to another user")
+	for _, path := range []string{folder, file} {
+		info, _ := os.Stat(path)
+		assert.Zero(t, info.Mode().Perm()&0077, "The files must never be readable by the other users")
+	}
+}
+
+func TestGetSecretMountsNotShared(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("The files can only be given to another user by root")
+	}
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	config := &TGFConfig{SecretsAsFiles: true, SecretVariables: []string{"GITHUB_TOKEN"}, Environment: map[string]string{}}
+	mounts, cleanup := config.getSecretMounts("1234")
+	defer cleanup()
+	assert.Empty(t, mounts)
+	assert.Equal(t, "secret", os.Getenv("GITHUB_TOKEN"), "The secret stays in the environment")
+	assert.NotContains(t, config.Environment, "GITHUB_TOKEN_FILE")
+}
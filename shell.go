@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	defaultShell       = "sh"
+	shellFolder        = forwardFolder + "/shell"
+	shellPromptVar     = "TGF_SHELL_PROMPT"
+	shellBashRC        = "bashrc"
+	shellZshRC         = ".zshrc"
+	shellStartupFolder = "shell"
+	shellIdentityDelay = 5 * time.Second
+)
+
+// The shells are listed by order of preference, sh is used if none of them is available in the image
+var shellCandidates = []string{"/bin/bash", "/usr/bin/bash", "/bin/zsh", "/usr/bin/zsh"}
+
+// The startup files load the user configuration before replacing the prompt
+var shellStartupFiles = map[string]string{
+	shellBashRC: strings.Join([]string{
+		`[ -f /etc/bash.bashrc ] && . /etc/bash.bashrc`,
+		`[ -f ~/.bashrc ] && . ~/.bashrc`,
+		`PS1='[$` + shellPromptVar + `] \w \$ '`,
+	}, "\n") + "\n",
+	shellZshRC: strings.Join([]string{
+		`[ -f "$HOME/.zshrc" ] && . "$HOME/.zshrc"`,
+		`setopt PROMPT_SUBST`,
+		`PROMPT='[$` + shellPromptVar + `] %~ %# '`,
+	}, "\n") + "\n",
+}
+
+// getShellCommand returns the command starting the best shell available in the image with a prompt showing the image version
+// and the AWS identity, along with the mounts of the startup files
+func (docker *dockerConfig) getShellCommand(imageName string) (command []string, mounts []TGFConfigMount, cleanup func()) {
+	config := docker.TGFConfig
+	cleanup = func() {}
+
+	prompt := []string{"tgf"}
+	if config.ImageVersion != nil && *config.ImageVersion != "" {
+		prompt = append(prompt, *config.ImageVersion)
+	}
+	if identity := getAWSIdentity(); identity != "" {
+		prompt = append(prompt, identity)
+	}
+	config.Environment[shellPromptVar] = strings.Join(prompt, " ")
+
+	shell := defaultShell
+	if docker.tgf.DryRun && !checkImage(imageName) {
+		log.Warningf("The image %s is not available locally, %s is used as shell", imageName, shell)
+	} else {
+		shell = findShell(imageName)
+	}
+
+	switch path.Base(shell) {
+	case "bash", "zsh":
+		folder, folderCleanup, err := getShellFolder(docker.tgf.DryRun || docker.tgf.Export)
+		if err != nil {
+			log.Warningf("Unable to create the shell startup files: %v", err)
+			break
+		}
+		cleanup = folderCleanup
+		for name, content := range shellStartupFiles {
+			file := filepath.Join(folder, name)
+			must(os.WriteFile(file, []byte(content), 0644))
+			mounts = append(mounts, TGFConfigMount{Source: file, Target: path.Join(shellFolder, name), ReadOnly: true})
+		}
+		if path.Base(shell) == "bash" {
+			return append([]string{shell, "--rcfile", path.Join(shellFolder, shellBashRC)}, docker.tgf.Unmanaged...), mounts, cleanup
+		}
+		config.Environment["ZDOTDIR"] = shellFolder
+		return append([]string{shell}, docker.tgf.Unmanaged...), mounts, cleanup
+	}
+	config.Environment["PS1"] = fmt.Sprintf("[%s] $ ", config.Environment[shellPromptVar])
+	return append([]string{shell}, docker.tgf.Unmanaged...), mounts, cleanup
+}
+
+// getShellFolder returns the folder where the startup files are written. The printed and exported definitions use the files after
+// tgf exits, so they are kept in ~/.tgf (their content never changes) instead of a temporary folder removed at the end of the run.
+func getShellFolder(persistent bool) (folder string, cleanup func(), err error) {
+	if !persistent {
+		folder, err = os.MkdirTemp("", "tgf-shell")
+		return folder, func() { os.RemoveAll(folder) }, err
+	}
+	filename, err := getTouchFilename(shellStartupFolder)
+	if err != nil {
+		return "", nil, err
+	}
+	folder = filepath.Join(filepath.Dir(filename), shellStartupFolder)
+	return folder, func() {}, os.MkdirAll(folder, 0755)
+}
+
+// findShell returns the preferred shell available in the image
+func findShell(imageName string) (shell string) {
+	shell = defaultShell
+	err := withContainer(&container.Config{Image: imageName, Cmd: []string{defaultShell}}, nil, func(id string) error {
+		cli, ctx := getDockerClient()
+		for _, candidate := range shellCandidates {
+			if _, err := cli.ContainerStatPath(ctx, id, candidate); err == nil {
+				shell = candidate
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Debugf("Unable to find the shells available in %s: %v", imageName, err)
+	}
+	return
+}
+
+// getAWSIdentity returns a short description of the current AWS identity (name@account), empty if there is no AWS session
+func getAWSIdentity() string {
+	if cachedAwsConfig == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shellIdentityDelay)
+	defer cancel()
+	identity, err := sts.NewFromConfig(*cachedAwsConfig).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Debugf("Unable to get the AWS identity: %v", err)
+		return ""
+	}
+	return formatAWSIdentity(aws.ToString(identity.Arn), aws.ToString(identity.Account))
+}
+
+// formatAWSIdentity returns the role name for an assumed role and the last part of the resource name otherwise
+func formatAWSIdentity(arn, account string) string {
+	parts := strings.Split(arn[strings.LastIndex(arn, ":")+1:], "/")
+	name := parts[len(parts)-1]
+	if parts[0] == "assumed-role" && len(parts) > 1 {
+		name = parts[1]
+	}
+	return name + "@" + account
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAWSIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/Administrator/jsmith@example.com", "Administrator@123456789012"},
+		{"arn:aws:iam::123456789012:user/jsmith", "jsmith@123456789012"},
+		{"arn:aws:iam::123456789012:user/division/jsmith", "jsmith@123456789012"},
+		{"arn:aws:iam::123456789012:root", "root@123456789012"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatAWSIdentity(tt.arn, "123456789012"), tt.arn)
+	}
+}
+
+func TestShellStartupFiles(t *testing.T) {
+	t.Parallel()
+
+	assert.Contains(t, shellStartupFiles[shellBashRC], `PS1='[$TGF_SHELL_PROMPT] \w \$ '`)
+	assert.Contains(t, shellStartupFiles[shellZshRC], `PROMPT='[$TGF_SHELL_PROMPT] %~ %# '`)
+}
+
+func TestGetShellFolder(t *testing.T) {
+	t.Parallel()
+
+	folder, cleanup, err := getShellFolder(false)
+	assert.NoError(t, err)
+	assert.DirExists(t, folder)
+	cleanup()
+	assert.NoDirExists(t, folder, "The temporary folder is removed at the end of the run")
+
+	folder, cleanup, err = getShellFolder(true)
+	assert.NoError(t, err)
+	cleanup()
+	assert.DirExists(t, folder, "The folder is kept for the printed and exported definitions")
+	assert.Equal(t, filepath.Join(".tgf", shellStartupFolder), filepath.Join(filepath.Base(filepath.Dir(folder)), filepath.Base(folder)))
+}
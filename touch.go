@@ -49,3 +49,23 @@ func touchImageRefresh(image string) {
 func lastRefresh(image string) time.Duration {
 	return time.Since(getLastRefresh(image))
 }
+
+// The last use of docker resources (built images, volumes) is tracked with the same touch files as the refresh
+// delays, using a distinct key to avoid any confusion with the refresh of an image
+const lastUsePrefix = "last-use:"
+
+func touchLastUse(resource string) {
+	touchImageRefresh(lastUsePrefix + resource)
+}
+
+func getLastUse(resource string) time.Time {
+	return getLastRefresh(lastUsePrefix + resource)
+}
+
+// touchUntil sets the time of the touch file to the specified time, it is used to keep track of an expiry
+func touchUntil(key string, t time.Time) {
+	touchImageRefresh(key)
+	if filename, err := getTouchFilename(key); err == nil {
+		os.Chtimes(filename, t, t)
+	}
+}
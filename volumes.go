@@ -0,0 +1,229 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-units"
+)
+
+// Folder where the managed volume is mounted in the helper containers
+const volumeMountPath = "/volume"
+
+// listVolumes prints the tgf volumes along with their size and last use
+func listVolumes() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "VOLUME\tSIZE\tLAST USED\tCONTAINERS")
+	for _, v := range getTgfVolumes() {
+		size, containers := "N/A", "N/A"
+		if v.UsageData != nil {
+			if v.UsageData.Size >= 0 {
+				size = units.HumanSize(float64(v.UsageData.Size))
+			}
+			if v.UsageData.RefCount >= 0 {
+				containers = fmt.Sprint(v.UsageData.RefCount)
+			}
+		}
+		// The listing must not start tracking the volumes, it would delay their cleanup
+		lastUse := "unknown"
+		if t := getLastUse(v.Name); !t.IsZero() {
+			lastUse = units.HumanDuration(time.Since(t)) + " ago"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Name, size, lastUse, containers)
+	}
+	w.Flush()
+}
+
+// wipeVolumes removes the specified tgf volumes, they are recreated empty on the next run
+func wipeVolumes(names ...string) (exitCode int) {
+	cli, ctx := getDockerClient()
+	for _, name := range names {
+		if err := checkVolume(name); err != nil {
+			log.Error(err)
+			exitCode = 1
+			continue
+		}
+		if err := cli.VolumeRemove(ctx, name, false); err != nil {
+			log.Errorf("Unable to wipe volume %s: %v", name, err)
+			exitCode = 1
+			continue
+		}
+		log.Info("Wiped volume ", name)
+	}
+	return
+}
+
+// volumeShell starts an interactive shell with the specified volume mounted as the current folder
+func (docker *dockerConfig) volumeShell(name, image string) int {
+	if err := checkVolume(name); err != nil {
+		log.Error(err)
+		return 1
+	}
+	shellCmd := exec.Command("docker", "run", "--rm", "-it", "--user", "root",
+		"--mount", fmt.Sprintf("type=volume,source=%s,target=%s", name, volumeMountPath), "-w", volumeMountPath,
+		"--entrypoint", "sh", image)
+	shellCmd.Stdin, shellCmd.Stdout, shellCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	log.Debug(strings.Join(shellCmd.Args, " "))
+	if err := shellCmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		log.Error(err)
+		return 1
+	}
+	return 0
+}
+
+// exportVolume writes the content of the volume into a tarball (compressed if its name ends with .gz or .tgz)
+func (docker *dockerConfig) exportVolume(name, image, archive string) int {
+	if err := checkVolume(name); err != nil {
+		log.Error(err)
+		return 1
+	}
+	archive = getVolumeArchiveName(name, archive)
+	err := withVolumeContainer(name, &container.Config{Image: image}, func(id string) error {
+		cli, ctx := getDockerClient()
+		content, _, err := cli.CopyFromContainer(ctx, id, volumeMountPath)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+
+		out, err := createArchive(archive)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, content); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+	if err != nil {
+		log.Errorf("Unable to export volume %s to %s: %v", name, archive, err)
+		return 1
+	}
+	log.Infof("Exported volume %s to %s", name, archive)
+	return 0
+}
+
+// importVolume restores the content of a tarball created by exportVolume into the volume, existing files are overwritten
+func (docker *dockerConfig) importVolume(name, image, archive string) int {
+	if !isTgfVolume(name) {
+		log.Errorf("%s is not a tgf volume", name)
+		return 1
+	}
+	archive = getVolumeArchiveName(name, archive)
+	in, err := openArchive(archive)
+	if err != nil {
+		log.Errorf("Unable to read %s: %v", archive, err)
+		return 1
+	}
+	defer in.Close()
+
+	err = withVolumeContainer(name, &container.Config{Image: image}, func(id string) error {
+		cli, ctx := getDockerClient()
+		return cli.CopyToContainer(ctx, id, "/", in, container.CopyToContainerOptions{CopyUIDGID: true})
+	})
+	if err != nil {
+		log.Errorf("Unable to import %s into volume %s: %v", archive, name, err)
+		return 1
+	}
+	log.Infof("Imported %s into volume %s", archive, name)
+	return 0
+}
+
+// withVolumeContainer creates a container (that is not started) with the volume mounted to be able to copy files from and to it
+func withVolumeContainer(name string, config *container.Config, action func(id string) error) error {
+	return withContainer(config, &container.HostConfig{Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: name, Target: volumeMountPath}}}, action)
+}
+
+// withContainer creates a temporary container, executes the action on it and removes it
+func withContainer(config *container.Config, hostConfig *container.HostConfig, action func(id string) error) error {
+	cli, ctx := getDockerClient()
+	created, err := cli.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := cli.ContainerRemove(ctx, created.ID, container.RemoveOptions{Force: true}); err != nil {
+			log.Warningf("Unable to remove the temporary container %s: %v", created.ID, err)
+		}
+	}()
+	return action(created.ID)
+}
+
+// checkVolume ensures that the volume exists and has been created by tgf
+func checkVolume(name string) error {
+	if !isTgfVolume(name) {
+		return fmt.Errorf("%s is not a tgf volume", name)
+	}
+	cli, ctx := getDockerClient()
+	if _, err := cli.VolumeInspect(ctx, name); err != nil {
+		return fmt.Errorf("unable to find volume %s: %v", name, err)
+	}
+	return nil
+}
+
+func getVolumeArchiveName(name, archive string) string {
+	if archive == "" {
+		return name + ".tar.gz"
+	}
+	return archive
+}
+
+func isCompressedArchive(archive string) bool {
+	return strings.HasSuffix(archive, ".gz") || strings.HasSuffix(archive, ".tgz")
+}
+
+type gzipFileWriter struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (w gzipFileWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func createArchive(archive string) (io.WriteCloser, error) {
+	file, err := os.Create(archive)
+	if err != nil || !isCompressedArchive(archive) {
+		return file, err
+	}
+	return gzipFileWriter{gzip.NewWriter(file), file}, nil
+}
+
+type gzipFileReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r gzipFileReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+func openArchive(archive string) (io.ReadCloser, error) {
+	file, err := os.Open(archive)
+	if err != nil || !isCompressedArchive(archive) {
+		return file, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipFileReader{reader, file}, nil
+}
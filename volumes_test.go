@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVolumeArchiveName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "tgf.tar.gz", getVolumeArchiveName("tgf", ""))
+	assert.Equal(t, "backup.tar", getVolumeArchiveName("tgf", "backup.tar"))
+}
+
+func TestVolumeArchiveRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"volume.tar", "volume.tar.gz", "volume.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), name)
+			out, err := createArchive(archive)
+			assert.NoError(t, err)
+			_, err = io.WriteString(out, "content")
+			assert.NoError(t, err)
+			assert.NoError(t, out.Close())
+
+			in, err := openArchive(archive)
+			assert.NoError(t, err)
+			defer in.Close()
+			content, err := io.ReadAll(in)
+			assert.NoError(t, err)
+			assert.Equal(t, "content", string(content))
+		})
+	}
+}